@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"fp/pkg/fp"
+	"os"
+	"runtime"
+	"time"
+)
+
+const fib = `(let fib (lambda n (case (sign (sub n 1)) 1 (add (fib (sub n 1)) (fib (sub n 2))) _ n)))`
+
+// bench : time of (fib n) on r
+func bench(r *fp.Runtime, n int) (fp.Object, time.Duration, error) {
+	exprs, remaining := fp.ParseAll(fp.Tokenize(fmt.Sprintf("%s (fib %d)", fib, n)))
+	if len(exprs) != 2 || len(remaining) != 0 {
+		return nil, 0, fmt.Errorf("parse error")
+	}
+	ctx := context.Background()
+	if _, err := r.Step(ctx, exprs[0]); err != nil {
+		return nil, 0, err
+	}
+	start := time.Now()
+	o, err := r.Step(ctx, exprs[1])
+	return o, time.Since(start), err
+}
+
+func main() {
+	n := flag.Int("n", 22, "compute (fib n)")
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines evaluating arguments in parallel")
+	flag.Parse()
+
+	sequential, seqTime, err := bench(fp.NewStdRuntime(), *n)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("sequential: (fib %d) = %v in %v\n", *n, sequential, seqTime)
+
+	parallel, parTime, err := bench(fp.NewStdRuntime(fp.WithParallelEvaluation(*workers)), *n)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("parallel (%d workers): (fib %d) = %v in %v\n", *workers, *n, parallel, parTime)
+	fmt.Printf("speed-up: %.2fx\n", float64(seqTime)/float64(parTime))
+}
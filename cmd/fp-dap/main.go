@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fp-dap : Debug Adapter Protocol server over stdio, launches a script with fp.NewStdRuntime
+//
+// requests are handled in order on the main goroutine, the program runs on its own goroutine
+func main() {
+	if err := serve(os.Stdin, os.Stdout); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// serve : handle the requests read from in until the client disconnects or in ends
+func serve(in io.Reader, out io.Writer) error {
+	c := &conn{w: out}
+	s := newSession(c)
+	reader := bufio.NewReader(in)
+	for {
+		req, err := readRequest(reader)
+		if err != nil {
+			s.cancel()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if !s.handle(req) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// client : scripted Debug Adapter Protocol client of serve
+type client struct {
+	t      *testing.T
+	w      io.Writer
+	r      *bufio.Reader
+	seq    int
+	events []map[string]any // received while waiting for responses
+}
+
+type message map[string]any
+
+func (c *client) send(command string, arguments any) int {
+	c.t.Helper()
+	c.seq++
+	body, err := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": arguments})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.seq
+}
+
+func (c *client) read() message {
+	c.t.Helper()
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		c.t.Fatal(err)
+	}
+	var m message
+	if err := json.Unmarshal(body, &m); err != nil {
+		c.t.Fatal(err)
+	}
+	return m
+}
+
+// request : body of the successful response to the request
+func (c *client) request(command string, arguments any) map[string]any {
+	c.t.Helper()
+	seq := c.send(command, arguments)
+	for {
+		m := c.read()
+		if m["type"] == "event" {
+			c.events = append(c.events, m)
+			continue
+		}
+		if m["request_seq"] != float64(seq) {
+			continue
+		}
+		if m["success"] != true {
+			c.t.Fatalf("%s failed: %v", command, m["message"])
+		}
+		body, _ := m["body"].(map[string]any)
+		return body
+	}
+}
+
+// event : body of the next event called name
+func (c *client) event(name string) map[string]any {
+	c.t.Helper()
+	for {
+		var m message
+		if len(c.events) > 0 {
+			m, c.events = c.events[0], c.events[1:]
+		} else {
+			m = c.read()
+		}
+		if m["type"] == "event" && m["event"] == name {
+			body, _ := m["body"].(map[string]any)
+			return body
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "program.lisp")
+	source := "(let a (del zz))\n(let b (list 1 2))\n(print b)\n"
+	if err := os.WriteFile(program, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(inR, outW)
+		_ = outW.Close()
+	}()
+	c := &client{t: t, w: inW, r: bufio.NewReader(outR)}
+	timer := time.AfterFunc(10*time.Second, func() {
+		_ = inW.CloseWithError(fmt.Errorf("timeout"))
+		_ = outR.CloseWithError(fmt.Errorf("timeout"))
+	})
+	defer timer.Stop()
+
+	c.request("initialize", map[string]any{"adapterID": "fp"})
+	c.event("initialized")
+	c.request("launch", map[string]any{"program": program})
+	breakpoints := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": program},
+		"breakpoints": []any{map[string]any{"line": 3}},
+	})["breakpoints"].([]any)
+	if len(breakpoints) != 1 || breakpoints[0].(map[string]any)["verified"] != true {
+		t.Fatalf("breakpoints: %v", breakpoints)
+	}
+	c.request("configurationDone", nil)
+	if stopped := c.event("stopped"); stopped["reason"] != "breakpoint" {
+		t.Fatalf("stopped: %v", stopped)
+	}
+
+	frames := c.request("stackTrace", map[string]any{"threadId": threadID})["stackFrames"].([]any)
+	frame := frames[len(frames)-1].(map[string]any)
+	if frame["line"] != float64(3) {
+		t.Errorf("program frame at line %v, want 3", frame["line"])
+	}
+	scopes := c.request("scopes", map[string]any{"frameId": frame["id"]})["scopes"].([]any)
+	globals := scopes[0].(map[string]any)
+	if globals["name"] != "Globals" {
+		t.Fatalf("scopes: %v", scopes)
+	}
+	values := make(map[string]map[string]any)
+	for _, v := range c.request("variables", map[string]any{"variablesReference": globals["variablesReference"]})["variables"].([]any) {
+		v := v.(map[string]any)
+		values[v["name"].(string)] = v
+	}
+	if a := values["a"]; a == nil || a["value"] != "nil" {
+		t.Errorf("a: %v, want nil", a)
+	}
+	b := values["b"]
+	if b == nil || b["value"] != "[1,2,]" {
+		t.Fatalf("b: %v, want [1,2,]", b)
+	}
+	elems := c.request("variables", map[string]any{"variablesReference": b["variablesReference"]})["variables"].([]any)
+	if len(elems) != 2 || elems[1].(map[string]any)["value"] != "2" {
+		t.Errorf("elements of b: %v", elems)
+	}
+
+	c.request("continue", map[string]any{"threadId": threadID})
+	if output := c.event("output"); output["output"] != "[1,2,] " {
+		t.Errorf("output: %v", output)
+	}
+	if exited := c.event("exited"); exited["exitCode"] != float64(0) {
+		t.Errorf("exited: %v", exited)
+	}
+	c.event("terminated")
+	c.request("disconnect", nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// request : client request of the Debug Adapter Protocol
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type response struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Command    string `json:"command"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int    `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+// readRequest : Content-Length header, empty line, json body
+func readRequest(reader *bufio.Reader) (request, error) {
+	header, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		return request{}, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return request{}, fmt.Errorf("invalid Content-Length: %w", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return request{}, err
+	}
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return request{}, err
+	}
+	return req, nil
+}
+
+// conn : writes responses and events, events are sent by the goroutine running the program too
+type conn struct {
+	mtx sync.Mutex
+	w   io.Writer
+	seq int
+}
+
+func (c *conn) send(seq func(int) any) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.seq++
+	body, err := json.Marshal(seq(c.seq))
+	if err != nil {
+		panic(err)
+	}
+	_, _ = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (c *conn) respond(req request, body any) {
+	c.send(func(seq int) any {
+		return response{Seq: seq, Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: true, Body: body}
+	})
+}
+
+func (c *conn) fail(req request, err error) {
+	c.send(func(seq int) any {
+		return response{Seq: seq, Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: err.Error()}
+	})
+}
+
+func (c *conn) event(name string, body any) {
+	c.send(func(seq int) any {
+		return event{Seq: seq, Type: "event", Event: name, Body: body}
+	})
+}
+
+// outputWriter : program output as output events
+type outputWriter struct {
+	c        *conn
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.c.event("output", map[string]any{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"fp/pkg/debugger"
+	"fp/pkg/fp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// threadID : the program runs on a single thread, forks never pause
+const threadID = 1
+
+// session : one debugged program
+type session struct {
+	c        *conn
+	runtime  *fp.Runtime
+	debugger *debugger.Debugger
+
+	program     string // absolute path of the script
+	exprs       []fp.Expr
+	stopOnEntry bool
+	lines       map[string][]lineBreakpoint // by absolute path, set before or after launch
+	started     bool
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	mtx    sync.Mutex
+	paused bool
+	reason string     // reason of the next pause without breakpoint
+	pos    fp.Pos     // of the paused expression
+	frames []fp.Frame // locals of the frames of the last stackTrace
+	refs   []any      // fp.Frame, fp.List or fp.Dict by variablesReference - 1, valid while paused
+}
+
+type lineBreakpoint struct {
+	Line      int    `json:"line"`
+	Condition string `json:"condition,omitempty"`
+}
+
+func newSession(c *conn) *session {
+	s := &session{
+		c:      c,
+		lines:  make(map[string][]lineBreakpoint),
+		reason: "step",
+	}
+	// stdin carries the protocol, the program reads nothing
+	s.runtime = fp.NewStdRuntime(
+		fp.WithStdout(outputWriter{c: c, category: "stdout"}),
+		fp.WithStderr(outputWriter{c: c, category: "stderr"}),
+		fp.WithStdin(strings.NewReader("")),
+	)
+	s.debugger = debugger.New(s.runtime)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// handle : answer one request, returns false once the client disconnected
+func (s *session) handle(req request) bool {
+	var err error
+	switch req.Command {
+	case "initialize":
+		s.c.respond(req, map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsTerminateRequest":         true,
+		})
+		s.c.event("initialized", nil)
+		return true
+	case "launch":
+		err = s.launch(req)
+	case "setBreakpoints":
+		err = s.setBreakpoints(req)
+	case "setFunctionBreakpoints":
+		err = s.setFunctionBreakpoints(req)
+	case "setExceptionBreakpoints":
+		s.c.respond(req, map[string]any{"breakpoints": []any{}})
+	case "configurationDone":
+		err = s.start(req)
+	case "threads":
+		s.c.respond(req, map[string]any{"threads": []any{map[string]any{"id": threadID, "name": "main"}}})
+	case "stackTrace":
+		err = s.stackTrace(req)
+	case "scopes":
+		err = s.scopes(req)
+	case "variables":
+		err = s.variables(req)
+	case "continue", "next", "stepIn", "stepOut":
+		err = s.resume(req)
+	case "pause":
+		s.mtx.Lock()
+		if !s.paused {
+			s.reason = "pause"
+			s.debugger.Pause()
+		}
+		s.mtx.Unlock()
+		s.c.respond(req, nil)
+	case "terminate":
+		s.cancel()
+		s.c.respond(req, nil)
+	case "disconnect":
+		s.cancel()
+		s.c.respond(req, nil)
+		return false
+	default:
+		err = fmt.Errorf("unsupported request %s", req.Command)
+	}
+	if err != nil {
+		s.c.fail(req, err)
+	}
+	return true
+}
+
+func (s *session) launch(req request) error {
+	var args struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+		NoDebug     bool   `json:"noDebug"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	program, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+	source, err := os.ReadFile(program)
+	if err != nil {
+		return err
+	}
+	if s.exprs, err = fp.ParseSource(string(source)); err != nil {
+		return fmt.Errorf("%s:%w", program, err)
+	}
+	s.program, s.stopOnEntry = program, args.StopOnEntry
+	if args.NoDebug {
+		s.runtime.Options.Debugger = nil
+	}
+	s.applyLines(program)
+	s.c.respond(req, nil)
+	return nil
+}
+
+// parseCondition : condition of a breakpoint, nil if empty
+func parseCondition(source string) (fp.Expr, error) {
+	if source == "" {
+		return nil, nil
+	}
+	exprs, err := fp.ParseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(exprs) != 1 {
+		return nil, fmt.Errorf("condition must be one expression")
+	}
+	return exprs[0], nil
+}
+
+// callLines : lines where a call starts, a line breakpoint can only pause there
+func callLines(exprs []fp.Expr, lines map[int]bool) map[int]bool {
+	for _, expr := range exprs {
+		if e, ok := expr.(fp.LambdaExpr); ok {
+			lines[e.Pos.Line] = true
+			callLines(e.Args, lines)
+		}
+	}
+	return lines
+}
+
+// applyLines : state of the breakpoints of path for the client, they are set on the debugger if path is the program
+//
+// before launch the program is not known yet, breakpoints are checked again by launch
+func (s *session) applyLines(path string) []map[string]any {
+	var calls map[int]bool
+	if path == s.program {
+		s.debugger.ClearLines()
+		calls = callLines(s.exprs, make(map[int]bool))
+	}
+	states := []map[string]any{}
+	for _, bp := range s.lines[path] {
+		state := map[string]any{"line": bp.Line, "verified": true}
+		condition, err := parseCondition(bp.Condition)
+		switch {
+		case err != nil:
+			state["verified"], state["message"] = false, err.Error()
+		case s.program != "" && calls == nil:
+			state["verified"], state["message"] = false, "not the launched program"
+		case calls != nil && !calls[bp.Line]:
+			state["verified"], state["message"] = false, "no call starts on this line"
+		case calls != nil:
+			s.debugger.BreakLine(bp.Line, condition)
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+func (s *session) setBreakpoints(req request) error {
+	var args struct {
+		Source struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Breakpoints []lineBreakpoint `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	path, err := filepath.Abs(args.Source.Path)
+	if err != nil {
+		return err
+	}
+	s.lines[path] = args.Breakpoints
+	s.c.respond(req, map[string]any{"breakpoints": s.applyLines(path)})
+	return nil
+}
+
+func (s *session) setFunctionBreakpoints(req request) error {
+	var args struct {
+		Breakpoints []struct {
+			Name      string `json:"name"`
+			Condition string `json:"condition,omitempty"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	for _, bp := range s.debugger.Breakpoints() {
+		if bp.Name != "" {
+			s.debugger.Clear(bp.Name)
+		}
+	}
+	states := []map[string]any{}
+	for _, bp := range args.Breakpoints {
+		condition, err := parseCondition(bp.Condition)
+		if err != nil {
+			states = append(states, map[string]any{"verified": false, "message": err.Error()})
+			continue
+		}
+		s.debugger.Break(fp.String(bp.Name), condition)
+		states = append(states, map[string]any{"verified": true})
+	}
+	s.c.respond(req, map[string]any{"breakpoints": states})
+	return nil
+}
+
+// start : run the program once the client is configured
+func (s *session) start(req request) error {
+	if s.program == "" {
+		return fmt.Errorf("no program launched")
+	}
+	if s.started {
+		return fmt.Errorf("program already started")
+	}
+	s.started = true
+	if s.stopOnEntry {
+		s.reason = "entry"
+		s.debugger.Pause()
+	}
+	s.c.respond(req, nil)
+	done := make(chan struct{})
+	go s.forwardPauses(done)
+	go func() {
+		defer close(done)
+		s.c.event("exited", map[string]any{"exitCode": s.run()})
+		s.c.event("terminated", nil)
+	}()
+	return nil
+}
+
+// run : evaluate the program like cmd/repl, errors are reported and the next expressions still run
+func (s *session) run() int {
+	code := 0
+	for _, expr := range s.exprs {
+		expr, err := s.runtime.Expand(s.ctx, expr)
+		if err == nil {
+			_, err = s.runtime.Step(s.ctx, expr)
+		}
+		if err != nil {
+			code = 1
+			s.c.event("output", map[string]any{"category": "stderr", "output": err.Error() + "\n"})
+		}
+		if s.ctx.Err() != nil {
+			break
+		}
+	}
+	return code
+}
+
+// forwardPauses : stopped event for every pause of the debugger
+func (s *session) forwardPauses(done <-chan struct{}) {
+	for {
+		select {
+		case p := <-s.debugger.Paused():
+			s.mtx.Lock()
+			s.paused, s.pos, s.refs, s.frames = true, p.Pos, nil, nil
+			body := map[string]any{"reason": s.reason, "threadId": threadID, "allThreadsStopped": true}
+			s.reason = "step"
+			s.mtx.Unlock()
+			if p.Breakpoint != nil {
+				body["reason"] = "breakpoint"
+				if p.Breakpoint.Name != "" {
+					body["reason"] = "function breakpoint"
+				}
+				body["description"] = p.String()
+			}
+			if p.Err != nil {
+				body["text"] = p.Err.Error()
+			}
+			s.c.event("stopped", body)
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *session) resume(req request) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if !s.paused {
+		return fmt.Errorf("not paused")
+	}
+	s.paused, s.refs, s.frames = false, nil, nil
+	// respond first, the stopped event of the next pause must come after
+	s.c.respond(req, map[string]any{"allThreadsContinued": true})
+	switch req.Command {
+	case "continue":
+		s.debugger.Continue()
+	case "next":
+		s.debugger.Next()
+	case "stepIn":
+		s.debugger.Step()
+	case "stepOut":
+		s.debugger.Out()
+	}
+	return nil
+}
+
+func (s *session) source() map[string]any {
+	return map[string]any{"name": filepath.Base(s.program), "path": s.program}
+}
+
+// stackTrace : the innermost lambda call first, the program last
+//
+// a frame is at the position of the call it is evaluating, the innermost one at the paused expression
+func (s *session) stackTrace(req request) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if !s.paused {
+		return fmt.Errorf("not paused")
+	}
+	calls := s.debugger.Backtrace()
+	pos := s.pos
+	s.frames = nil
+	var frames []map[string]any
+	add := func(name string, pos fp.Pos, locals fp.Frame) {
+		s.frames = append(s.frames, locals)
+		frames = append(frames, map[string]any{
+			"id":     len(s.frames),
+			"name":   name,
+			"source": s.source(),
+			"line":   pos.Line,
+			"column": pos.Col,
+		})
+	}
+	for i, call := range calls {
+		locals := call.Locals
+		if i == 0 {
+			// the innermost frame may be a scope inside the call, like the one of with
+			locals = s.debugger.Locals()
+		}
+		add(string(call.Name), pos, locals)
+		pos = call.Pos
+	}
+	add("<program>", pos, s.debugger.Globals())
+	s.c.respond(req, map[string]any{"stackFrames": frames, "totalFrames": len(frames)})
+	return nil
+}
+
+// ref : variablesReference of o, 0 if o has no children
+func (s *session) ref(o any) int {
+	switch o := o.(type) {
+	case fp.Frame:
+		if len(o) == 0 {
+			return 0
+		}
+	case fp.List:
+		if len(o) == 0 {
+			return 0
+		}
+	case fp.Dict:
+		if len(o) == 0 {
+			return 0
+		}
+	default:
+		return 0
+	}
+	s.refs = append(s.refs, o)
+	return len(s.refs)
+}
+
+func (s *session) scopes(req request) error {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if args.FrameID < 1 || args.FrameID > len(s.frames) {
+		return fmt.Errorf("unknown frame %d", args.FrameID)
+	}
+	name := "Locals"
+	if args.FrameID == len(s.frames) {
+		name = "Globals"
+	}
+	frame := s.frames[args.FrameID-1]
+	s.c.respond(req, map[string]any{"scopes": []any{map[string]any{
+		"name":               name,
+		"variablesReference": s.ref(frame),
+		"namedVariables":     len(frame),
+		"expensive":          false,
+	}}})
+	return nil
+}
+
+func (s *session) variable(name string, o fp.Object) map[string]any {
+	return map[string]any{"name": name, "value": valueString(o), "variablesReference": s.ref(o)}
+}
+
+// valueString : o for the client, a name bound to the result of del is nil
+func valueString(o fp.Object) string {
+	if o == nil {
+		return "nil"
+	}
+	return o.String()
+}
+
+func (s *session) variables(req request) error {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if args.VariablesReference < 1 || args.VariablesReference > len(s.refs) {
+		return fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+	variables := []map[string]any{}
+	switch o := s.refs[args.VariablesReference-1].(type) {
+	case fp.Frame:
+		var names []string
+		for name := range o {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			variables = append(variables, s.variable(name, o[fp.String(name)]))
+		}
+	case fp.List:
+		for i, elem := range o {
+			variables = append(variables, s.variable("["+strconv.Itoa(i)+"]", elem))
+		}
+	case fp.Dict:
+		var keys []fp.Object
+		for k := range o {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		for _, k := range keys {
+			variables = append(variables, s.variable(k.String(), o[k]))
+		}
+	}
+	s.c.respond(req, map[string]any{"variables": variables})
+	return nil
+}
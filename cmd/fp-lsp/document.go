@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fp/pkg/fp"
+	"strings"
+	"unicode/utf8"
+)
+
+// position : zero-based line and UTF-16 offset in the line, as in the Language Server Protocol
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type textRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+// definition : (let name value) in the document
+type definition struct {
+	name  string
+	pos   fp.Pos // of name
+	value fp.Expr
+}
+
+// document : an open .lisp file, parsed on every change
+type document struct {
+	text    string
+	lines   []string
+	tokens  []fp.Token
+	posList []fp.Pos
+	exprs   []fp.Expr // expressions before the parse error, if any
+	err     error
+	defs    []definition
+}
+
+func newDocument(text string) *document {
+	d := &document{
+		text:  text,
+		lines: strings.Split(text, "\n"),
+	}
+	d.tokens, d.posList = fp.TokenizePos(text)
+	d.exprs, d.err = fp.ParseSource(text)
+	// the name of (let name value) is the second token after its (
+	index := make(map[fp.Pos]int, len(d.tokens))
+	for i, pos := range d.posList {
+		index[pos] = i
+	}
+	var walk func(exprs []fp.Expr)
+	walk = func(exprs []fp.Expr) {
+		for _, expr := range exprs {
+			e, ok := expr.(fp.LambdaExpr)
+			if !ok {
+				continue
+			}
+			if name, ok := letName(e); ok {
+				if i, ok := index[e.Pos]; ok && i+2 < len(d.tokens) {
+					d.defs = append(d.defs, definition{name: name, pos: d.posList[i+2], value: e.Args[1]})
+				}
+			}
+			walk(e.Args)
+		}
+	}
+	walk(d.exprs)
+	return d
+}
+
+// letName : name bound by (let name value)
+func letName(e fp.LambdaExpr) (string, bool) {
+	if e.Name != "let" || len(e.Args) != 2 {
+		return "", false
+	}
+	name, ok := e.Args[0].(fp.NameExpr)
+	return string(name), ok
+}
+
+// toPosition : LSP position of pos, columns of fp.Pos count runes
+func (d *document) toPosition(pos fp.Pos) position {
+	if pos.Line < 1 || pos.Line > len(d.lines) {
+		return position{}
+	}
+	line := d.lines[pos.Line-1]
+	character := 0
+	for i, r := range []rune(line) {
+		if i+1 >= pos.Col {
+			break
+		}
+		character += utf16Len(r)
+	}
+	return position{Line: pos.Line - 1, Character: character}
+}
+
+// fromPosition : fp.Pos of an LSP position
+func (d *document) fromPosition(p position) fp.Pos {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return fp.Pos{}
+	}
+	col, character := 1, 0
+	for _, r := range d.lines[p.Line] {
+		if character >= p.Character {
+			break
+		}
+		character += utf16Len(r)
+		col++
+	}
+	return fp.Pos{Line: p.Line + 1, Col: col}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// tokenRange : range of the token starting at pos
+func (d *document) tokenRange(token fp.Token, pos fp.Pos) textRange {
+	end := fp.Pos{Line: pos.Line, Col: pos.Col + utf8.RuneCountInString(token)}
+	return textRange{Start: d.toPosition(pos), End: d.toPosition(end)}
+}
+
+// nameAt : name under the cursor or just before it, parentheses and strings are not names
+func (d *document) nameAt(p position) (string, fp.Pos, bool) {
+	cursor := d.fromPosition(p)
+	for i, token := range d.tokens {
+		pos := d.posList[i]
+		if pos.Line != cursor.Line || pos.Col > cursor.Col || cursor.Col > pos.Col+utf8.RuneCountInString(token) {
+			continue
+		}
+		switch {
+		case token == "(" || token == ")" || token == "`" || token == "," || token == ",@":
+			continue
+		case strings.HasPrefix(token, `"`):
+			continue
+		}
+		return token, pos, true
+	}
+	return "", fp.Pos{}, false
+}
+
+// end : position after the last character
+func (d *document) end() position {
+	last := len(d.lines) - 1
+	character := 0
+	for _, r := range d.lines[last] {
+		character += utf16Len(r)
+	}
+	return position{Line: last, Character: character}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fp-lsp : Language Server Protocol server over stdio for fp scripts
+func main() {
+	s := newServer(&conn{w: os.Stdout})
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		msg, err := readMessage(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+			}
+			return
+		}
+		if !s.handle(msg) {
+			if !s.shutdown {
+				// exit without shutdown is an error, see the specification
+				os.Exit(1)
+			}
+			return
+		}
+	}
+}
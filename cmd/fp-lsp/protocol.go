@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// message : JSON-RPC 2.0 request, response or notification, notifications have no ID
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	codeInvalidParams  = -32602
+	codeMethodNotFound = -32601
+)
+
+// readMessage : Content-Length header, empty line, json body
+func readMessage(reader *bufio.Reader) (message, error) {
+	header, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		return message{}, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return message{}, fmt.Errorf("invalid Content-Length: %w", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return message{}, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return message{}, err
+	}
+	return msg, nil
+}
+
+type conn struct {
+	mtx sync.Mutex
+	w   io.Writer
+}
+
+func (c *conn) send(msg message) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	_, _ = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// reply : result of a request, a nil result is sent as null
+func (c *conn) reply(id json.RawMessage, result any) {
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	c.send(message{ID: id, Result: result})
+}
+
+func (c *conn) replyError(id json.RawMessage, code int, err error) {
+	c.send(message{ID: id, Error: &rpcError{Code: code, Message: err.Error()}})
+}
+
+func (c *conn) notify(method string, params any) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		panic(err)
+	}
+	c.send(message{Method: method, Params: body})
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fp/pkg/format"
+	"fp/pkg/fp"
+	"sort"
+	"strings"
+)
+
+// maxHoverValue : values of let longer than this are cut in hover and completion
+const maxHoverValue = 200
+
+// server : open documents and the builtins of fp.NewStdRuntime
+type server struct {
+	c        *conn
+	builtins fp.Frame
+	docs     map[string]*document // by uri
+	shutdown bool
+}
+
+func newServer(c *conn) *server {
+	return &server{
+		c:        c,
+		builtins: fp.NewStdRuntime().Stack[0],
+		docs:     make(map[string]*document),
+	}
+}
+
+type textDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position position `json:"position"`
+}
+
+// handle : answer one message, returns false on exit
+func (s *server) handle(msg message) bool {
+	var result any
+	var err error
+	switch msg.Method {
+	case "initialize":
+		result = map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":           1, // full document on every change
+				"hoverProvider":              true,
+				"completionProvider":         map[string]any{},
+				"definitionProvider":         true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "fp-lsp"},
+		}
+	case "initialized":
+		return true
+	case "shutdown":
+		s.shutdown = true
+	case "exit":
+		return false
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err = json.Unmarshal(msg.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			delete(s.docs, params.TextDocument.URI)
+			s.c.notify("textDocument/publishDiagnostics", map[string]any{"uri": params.TextDocument.URI, "diagnostics": []any{}})
+		}
+	case "textDocument/hover":
+		result, err = s.hover(msg.Params)
+	case "textDocument/completion":
+		result, err = s.completion(msg.Params)
+	case "textDocument/definition":
+		result, err = s.definition(msg.Params)
+	case "textDocument/formatting":
+		result, err = s.formatting(msg.Params)
+	default:
+		if msg.ID != nil {
+			s.c.replyError(msg.ID, codeMethodNotFound, fmt.Errorf("unsupported method %s", msg.Method))
+		}
+		return true
+	}
+	if msg.ID == nil {
+		// notifications have no response
+		return true
+	}
+	if err != nil {
+		s.c.replyError(msg.ID, codeInvalidParams, err)
+		return true
+	}
+	s.c.reply(msg.ID, result)
+	return true
+}
+
+// update : parse the new text and publish its diagnostics
+func (s *server) update(uri string, text string) {
+	d := newDocument(text)
+	s.docs[uri] = d
+	s.c.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": d.diagnostics()})
+}
+
+func (d *document) diagnostic(pos fp.Pos, length int, message string) map[string]any {
+	end := fp.Pos{Line: pos.Line, Col: pos.Col + length}
+	return map[string]any{
+		"range":    textRange{Start: d.toPosition(pos), End: d.toPosition(end)},
+		"severity": 1, // error
+		"source":   "fp",
+		"message":  message,
+	}
+}
+
+// diagnostics : errors of the tokenizer and of the parser
+func (d *document) diagnostics() []map[string]any {
+	diagnostics := []map[string]any{}
+	if n := len(d.tokens); n > 0 && unterminated(d.tokens[n-1]) {
+		diagnostics = append(diagnostics, d.diagnostic(d.posList[n-1], 1, "unterminated string"))
+	}
+	var parseErr *fp.ParseError
+	if errors.As(d.err, &parseErr) {
+		diagnostics = append(diagnostics, d.diagnostic(parseErr.Pos, 1, parseErr.Err.Error()))
+	}
+	return diagnostics
+}
+
+// unterminated : the token is a string without its closing quote, only the last token can be
+func unterminated(token fp.Token) bool {
+	if !strings.HasPrefix(token, `"`) {
+		return false
+	}
+	escaped := false
+	for i, r := range token[1:] {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return i != len(token)-2
+		}
+	}
+	return true
+}
+
+func (s *server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, fmt.Errorf("document %s is not open", uri)
+	}
+	return d, nil
+}
+
+// cut : s on one line, at most n characters
+func cut(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
+
+func (s *server) hover(params json.RawMessage) (any, error) {
+	var p textDocumentPosition
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	name, pos, ok := d.nameAt(p.Position)
+	if !ok {
+		return nil, nil
+	}
+	var text string
+	for _, def := range d.defs {
+		if def.name == name {
+			// the last definition is the one used after the script ran
+			text = fmt.Sprintf("(let %s %s)", name, cut(def.value.String(), maxHoverValue))
+		}
+	}
+	if text == "" {
+		o, ok := s.builtins[fp.String(name)]
+		if !ok {
+			return nil, nil
+		}
+		text = o.String()
+	}
+	return map[string]any{
+		"contents": map[string]any{"kind": "plaintext", "value": text},
+		"range":    d.tokenRange(name, pos),
+	}, nil
+}
+
+func (s *server) completion(params json.RawMessage) (any, error) {
+	var p textDocumentPosition
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	const (
+		kindFunction = 3
+		kindVariable = 6
+	)
+	items := []map[string]any{}
+	seen := make(map[string]bool)
+	for i := len(d.defs) - 1; i >= 0; i-- {
+		def := d.defs[i]
+		if seen[def.name] {
+			continue
+		}
+		seen[def.name] = true
+		items = append(items, map[string]any{"label": def.name, "kind": kindVariable, "detail": cut(def.value.String(), maxHoverValue)})
+	}
+	var names []string
+	for name := range s.builtins {
+		if !seen[string(name)] {
+			names = append(names, string(name))
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		items = append(items, map[string]any{"label": name, "kind": kindFunction, "detail": s.builtins[fp.String(name)].String()})
+	}
+	return items, nil
+}
+
+func (s *server) definition(params json.RawMessage) (any, error) {
+	var p textDocumentPosition
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	name, _, ok := d.nameAt(p.Position)
+	if !ok {
+		return nil, nil
+	}
+	locations := []map[string]any{}
+	for _, def := range d.defs {
+		if def.name == name {
+			locations = append(locations, map[string]any{"uri": p.TextDocument.URI, "range": d.tokenRange(name, def.pos)})
+		}
+	}
+	return locations, nil
+}
+
+func (s *server) formatting(params json.RawMessage) (any, error) {
+	var p struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := format.Source(d.text)
+	if err != nil {
+		// the document does not parse yet, only fix its indentation
+		formatted = format.Indent(d.text)
+	}
+	if formatted == d.text {
+		return []any{}, nil
+	}
+	return []any{map[string]any{
+		"range":   textRange{Start: position{}, End: d.end()},
+		"newText": formatted,
+	}}, nil
+}
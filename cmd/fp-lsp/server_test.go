@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+)
+
+const uri = "file:///script.lisp"
+
+// positionParams : params of a request at line and character of the document
+func positionParams(t *testing.T, line int, character int) json.RawMessage {
+	t.Helper()
+	params, err := json.Marshal(map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     position{Line: line, Character: character},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+const script = `(let x 1)
+(let f (lambda n (add n x)))
+(let x "é" ) (f x)
+`
+
+func TestHover(t *testing.T) {
+	s := newServer(&conn{w: io.Discard})
+	s.update(uri, script)
+	tests := []struct {
+		line      int
+		character int
+		want      string // "" for no hover
+		start     position
+	}{
+		// the last definition of x
+		{0, 5, `(let x "é")`, position{0, 5}},
+		{1, 5, `(let f (lambda n (add n x)))`, position{1, 5}},
+		{2, 15, `(let f (lambda n (add n x)))`, position{2, 14}},
+		// builtins
+		{1, 19, "module: (add 1 (add 2 3) 3) - exec a sequence of expressions and return the sum", position{1, 18}},
+		{0, 2, "module: (let x 3) - assign value 3 to local variable x", position{0, 1}},
+		// parameters, literals and empty lines
+		{1, 16, "", position{}},
+		{0, 7, "", position{}},
+		{3, 0, "", position{}},
+	}
+	for _, test := range tests {
+		result, err := s.hover(positionParams(t, test.line, test.character))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.want == "" {
+			if result != nil {
+				t.Errorf("%d:%d: got %v, want no hover", test.line, test.character, result)
+			}
+			continue
+		}
+		hover, ok := result.(map[string]any)
+		if !ok {
+			t.Errorf("%d:%d: got no hover, want %s", test.line, test.character, test.want)
+			continue
+		}
+		contents := hover["contents"].(map[string]any)
+		if contents["value"] != test.want {
+			t.Errorf("%d:%d: got %s, want %s", test.line, test.character, contents["value"], test.want)
+		}
+		if r := hover["range"].(textRange); r.Start != test.start {
+			t.Errorf("%d:%d: range starts at %v, want %v", test.line, test.character, r.Start, test.start)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	s := newServer(&conn{w: io.Discard})
+	s.update(uri, script)
+	tests := []struct {
+		line      int
+		character int
+		want      []textRange
+	}{
+		// every let of x, the accented string counts as one UTF-16 unit
+		{1, 24, []textRange{{position{0, 5}, position{0, 6}}, {position{2, 5}, position{2, 6}}}},
+		{2, 16, []textRange{{position{0, 5}, position{0, 6}}, {position{2, 5}, position{2, 6}}}},
+		{2, 14, []textRange{{position{1, 5}, position{1, 6}}}},
+		// builtins and parameters are not defined in the document
+		{1, 19, []textRange{}},
+		{1, 16, []textRange{}},
+	}
+	for _, test := range tests {
+		result, err := s.definition(positionParams(t, test.line, test.character))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []textRange{}
+		for _, location := range result.([]map[string]any) {
+			if location["uri"] != uri {
+				t.Errorf("%d:%d: got uri %s", test.line, test.character, location["uri"])
+			}
+			got = append(got, location["range"].(textRange))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d:%d: got %v, want %v", test.line, test.character, got, test.want)
+		}
+	}
+	if _, err := s.definition(json.RawMessage(`{"textDocument": {"uri": "file:///other.lisp"}, "position": {"line": 0, "character": 0}}`)); err == nil {
+		t.Errorf("definition in a document not open: got no error")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"fp/pkg/typecheck"
+	"io"
+	"os"
+)
+
+// checkCommand : print the type errors of the files, and the types of their top level lets with -v, exit 1 if there is any error
+func checkCommand(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "print the type of every let at top level")
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return checkSource("<stdin>", string(source), *verbose)
+	}
+	code := 0
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			code = 1
+			continue
+		}
+		code = max(code, checkSource(path, string(source), *verbose))
+	}
+	return code
+}
+
+func checkSource(path string, source string, verbose bool) int {
+	defs, errs, err := typecheck.Check(source)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s:%s\n", path, err)
+		return 1
+	}
+	if verbose {
+		for _, def := range defs {
+			_, _ = fmt.Fprintf(os.Stdout, "%s:%s: %s : %s\n", path, def.Pos, def.Name, typecheck.TypeString(def.Type))
+		}
+	}
+	for _, err := range errs {
+		_, _ = fmt.Fprintf(os.Stdout, "%s:%s\n", path, err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	return 0
+}
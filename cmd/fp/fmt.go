@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"fp/pkg/format"
+	"io"
+	"os"
+)
+
+// fmtCommand : print the formatted files, or rewrite them with -w, or list the ones not formatted with -l
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		formatted, err := format.Source(string(source))
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "<stdin>:%s\n", err)
+			return 1
+		}
+		_, _ = fmt.Fprint(os.Stdout, formatted)
+		return 0
+	}
+	code := 0
+	for _, path := range flags.Args() {
+		if err := fmtFile(path, *write, *list); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+func fmtFile(path string, write bool, list bool) error {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	formatted, err := format.Source(string(source))
+	if err != nil {
+		return fmt.Errorf("%s:%w", path, err)
+	}
+	changed := formatted != string(source)
+	if list && changed {
+		_, _ = fmt.Fprintln(os.Stdout, path)
+	}
+	if write {
+		if changed {
+			return os.WriteFile(path, []byte(formatted), 0644)
+		}
+		return nil
+	}
+	if !list {
+		_, _ = fmt.Fprint(os.Stdout, formatted)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"fp/pkg/lint"
+	"io"
+	"os"
+)
+
+// lintCommand : print the diagnostics of the files, as a JSON array with -json, exit 1 if there is an error, or a warning with -strict
+func lintCommand(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the diagnostics as a JSON array")
+	strict := flags.Bool("strict", false, "exit 1 on warnings too")
+	rules := flags.Bool("rules", false, "list the rules and exit")
+	_ = flags.Parse(args)
+
+	if *rules {
+		for _, r := range lint.Rules {
+			_, _ = fmt.Fprintf(os.Stdout, "%-13s %-8s %s\n", r.Name, r.Severity, r.Doc)
+		}
+		return 0
+	}
+	code := 0
+	diagnostics := []lint.Diagnostic{}
+	lintSource := func(path string, source []byte) {
+		for _, d := range lint.Source(string(source)) {
+			d.File = path
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	if flags.NArg() == 0 {
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		lintSource("<stdin>", source)
+	}
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			code = 1
+			continue
+		}
+		lintSource(path, source)
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(diagnostics)
+	} else {
+		for _, d := range diagnostics {
+			_, _ = fmt.Fprintf(os.Stdout, "%s:%d:%d: %s: %s: %s\n", d.File, d.Line, d.Col, d.Severity, d.Rule, d.Message)
+		}
+	}
+	if lint.Failed(diagnostics, *strict) {
+		code = 1
+	}
+	return code
+}
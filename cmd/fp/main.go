@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// commands : fp <command> [flags] [files]
+var commands = map[string]func(args []string) int{
+	"check": checkCommand,
+	"fmt":   fmtCommand,
+	"lint":  lintCommand,
+}
+
+func usage() {
+	_, _ = fmt.Fprintln(os.Stderr, "usage: fp <command> [arguments]")
+	_, _ = fmt.Fprintln(os.Stderr, "commands:")
+	_, _ = fmt.Fprintln(os.Stderr, "    check [-v] [files]              report type errors of fp scripts, -v prints the types of the lets")
+	_, _ = fmt.Fprintln(os.Stderr, "    fmt [-w] [-l] [files]           format fp scripts, stdin if no file")
+	_, _ = fmt.Fprintln(os.Stderr, "    lint [-json] [-strict] [files]  report problems in fp scripts, -rules lists the checks")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		_, _ = fmt.Fprintf(os.Stderr, "fp: unknown command %s\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	os.Exit(command(os.Args[2:]))
+}
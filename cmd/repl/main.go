@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"fp/pkg/fp"
 	"fp/pkg/repl"
@@ -14,10 +16,63 @@ import (
 	"syscall"
 )
 
+// saveWorkspace : write the runtime snapshot to path if path is set, the previous file is kept if the snapshot fails
+func saveWorkspace(runtime *fp.Runtime, path string) {
+	if path == "" {
+		return
+	}
+	var b bytes.Buffer
+	skipped, err := runtime.Snapshot(&b)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "workspace %s not saved: %v\n", path, err)
+		return
+	}
+	for _, err := range skipped {
+		_, _ = fmt.Fprintf(os.Stderr, "workspace %s: %v, not saved\n", path, err)
+	}
+	// written next to path then renamed, so that path is never left half written
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0o644); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// loadWorkspace : restore the runtime snapshot from path if the file exists
+func loadWorkspace(runtime *fp.Runtime, path string) {
+	if path == "" {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+		}
+		return
+	}
+	defer f.Close()
+	if err := runtime.LoadSnapshot(f); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "workspace %s restored\n", path)
+}
+
 func main() {
+	workspace := flag.String("workspace", "", "file to restore the session from and to save it to on exit")
+	parallel := flag.Int("parallel", 0, "goroutines evaluating arguments of pure functions in parallel, 0 disables it")
+	flag.Parse()
+
 	replMtx := &sync.Mutex{}
-	repl, welcome := repl.NewFP(fp.NewStdRuntime())
-	_, _ = fmt.Fprintf(os.Stderr, welcome)
+	runtime := fp.NewStdRuntime(fp.WithParallelEvaluation(*parallel))
+	repl, welcome := repl.NewFP(runtime)
+	// keep program output on stdout so that scripts can be piped, see README
+	runtime.Stdout = os.Stdout
+	_, _ = fmt.Fprint(os.Stderr, welcome)
+	loadWorkspace(runtime, *workspace)
 
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt:          ">>> ",                 // Default prompt
@@ -29,15 +84,37 @@ func main() {
 	}
 	defer rl.Close()
 
-	var ctx context.Context
-	var cancel context.CancelFunc = func() {}
+	// every line runs in the session context so that spawned tasks outlive the line,
+	// interrupting cancels the session, including spawned tasks, and starts a new one
+	sessionMtx := &sync.Mutex{}
+	session, cancel := context.WithCancel(context.Background())
+	interrupt := func() {
+		sessionMtx.Lock()
+		defer sessionMtx.Unlock()
+		cancel()
+		session, cancel = context.WithCancel(context.Background())
+	}
+
+	// exit : stop the running line, save the workspace and exit, on every way to leave the repl
+	exit := func(code int) {
+		interrupt()
+		replMtx.Lock()
+		saveWorkspace(runtime, *workspace)
+		_ = rl.Close()
+		os.Exit(code)
+	}
 
 	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	go func() {
-		// receive SIGINT, SIGTERM when running code -> stop code
-		for range signalCh {
-			cancel()
+		for sig := range signalCh {
+			if sig == syscall.SIGINT {
+				// receive SIGINT when running code -> stop code
+				interrupt()
+				continue
+			}
+			// receive SIGTERM or SIGHUP -> exit
+			exit(0)
 		}
 	}()
 
@@ -45,7 +122,8 @@ func main() {
 		line, err := rl.Readline()
 		if err != nil {
 			if errors.Is(err, readline.ErrInterrupt) {
-				// receive SIGINT when typing -> clear buffer
+				// receive SIGINT when typing -> stop spawned tasks, clear buffer
+				interrupt()
 				func() {
 					replMtx.Lock()
 					defer replMtx.Unlock()
@@ -56,14 +134,16 @@ func main() {
 				}()
 				continue
 			} else if err == io.EOF {
-				// receive SIGTERM when typing -> exit
-				os.Exit(0)
+				// receive control + d when typing -> exit
+				exit(0)
 			}
-			panic(err)
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			exit(1)
 		}
-		ctx, cancel = context.WithCancel(context.Background())
+		sessionMtx.Lock()
+		ctx := session
+		sessionMtx.Unlock()
 		func() {
-			defer cancel()
 			replMtx.Lock()
 			defer replMtx.Unlock()
 			output, executed := repl.ReplyInput(ctx, line)
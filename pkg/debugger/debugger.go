@@ -0,0 +1,379 @@
+package debugger
+
+import (
+	"context"
+	"fmt"
+	"fp/pkg/fp"
+	"sort"
+	"sync"
+)
+
+// Breakpoint : pause when the lambda bound to Name is called, or when an expression starting on Line is evaluated,
+// and Condition, if any, is not 0
+type Breakpoint struct {
+	Name      fp.String
+	Line      int
+	Condition fp.Expr // evaluated in the frame of the call or of the expression
+	Hits      int
+}
+
+// Pause : the evaluation is paused before Expr
+type Pause struct {
+	Expr       fp.Expr
+	Pos        fp.Pos      // position of the innermost call with a position, see fp.ParseSource
+	Breakpoint *Breakpoint // nil when paused by a step
+	Err        error       // error of the condition of the breakpoint
+}
+
+// Call : lambda call being evaluated, see Debugger.Backtrace
+type Call struct {
+	Name   fp.String
+	Expr   fp.Expr
+	Pos    fp.Pos
+	Locals fp.Frame
+}
+
+type mode int
+
+const (
+	run  mode = iota // pause on breakpoints only
+	step             // pause before the next expression
+	next             // pause before the next expression not nested in the current one
+	out              // pause before the next expression not nested in the parent of the current one
+)
+
+// entry : expression being evaluated, call is set once its arguments are bound
+type entry struct {
+	expr   fp.Expr
+	pos    fp.Pos // of expr, or of the expression containing it
+	call   fp.String
+	lambda fp.Lambda
+	frame  int
+}
+
+// Debugger : breakpoints and stepping for one runtime, implements fp.Debugger
+//
+// the evaluation runs on its own goroutine, Paused tells when it stops,
+// Locals and Backtrace can be called while it is paused, Step, Next, Out and Continue resume it
+type Debugger struct {
+	r           *fp.Runtime
+	mtx         sync.Mutex
+	breakpoints map[fp.String]*Breakpoint
+	lines       map[int]*Breakpoint
+	mode        mode
+	depth       int     // depth of the paused expression, for next and out
+	exprs       []entry // expressions being evaluated, innermost last
+	evaluating  bool    // hooks are ignored while a condition is evaluated
+	paused      chan Pause
+	resume      chan mode
+}
+
+// New : debugger attached to r
+func New(r *fp.Runtime) *Debugger {
+	d := &Debugger{
+		r:           r,
+		breakpoints: make(map[fp.String]*Breakpoint),
+		lines:       make(map[int]*Breakpoint),
+		paused:      make(chan Pause),
+		resume:      make(chan mode),
+	}
+	r.Options.Debugger = d
+	return d
+}
+
+// Break : add or replace the breakpoint on name, condition may be nil
+func (d *Debugger) Break(name fp.String, condition fp.Expr) *Breakpoint {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	bp := &Breakpoint{Name: name, Condition: condition}
+	d.breakpoints[name] = bp
+	return bp
+}
+
+// BreakLine : add or replace the breakpoint on line, condition may be nil
+//
+// it pauses before the first call starting on line, not before the calls nested in it on the same line
+func (d *Debugger) BreakLine(line int, condition fp.Expr) *Breakpoint {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	bp := &Breakpoint{Line: line, Condition: condition}
+	d.lines[line] = bp
+	return bp
+}
+
+// Clear : remove the breakpoint on name
+func (d *Debugger) Clear(name fp.String) bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	_, ok := d.breakpoints[name]
+	delete(d.breakpoints, name)
+	return ok
+}
+
+// ClearLines : remove every breakpoint on a line
+func (d *Debugger) ClearLines() {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	clear(d.lines)
+}
+
+// Breakpoints : breakpoints on names sorted by name, then breakpoints on lines sorted by line
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	var bps []*Breakpoint
+	for _, bp := range d.breakpoints {
+		bps = append(bps, bp)
+	}
+	for _, bp := range d.lines {
+		bps = append(bps, bp)
+	}
+	sort.Slice(bps, func(i, j int) bool {
+		a, b := bps[i], bps[j]
+		if (a.Name == "") != (b.Name == "") {
+			return a.Name != ""
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Line < b.Line
+	})
+	return bps
+}
+
+// Paused : receives a Pause every time the evaluation stops
+func (d *Debugger) Paused() <-chan Pause {
+	return d.paused
+}
+
+func (d *Debugger) Step() {
+	d.resume <- step
+}
+
+func (d *Debugger) Next() {
+	d.resume <- next
+}
+
+func (d *Debugger) Out() {
+	d.resume <- out
+}
+
+func (d *Debugger) Continue() {
+	d.resume <- run
+}
+
+// Reset : stop stepping, the next evaluation runs until a breakpoint
+//
+// without it, stepping continues with the next evaluation, like the next expression of a script
+func (d *Debugger) Reset() {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.mode = run
+}
+
+// Pause : pause before the next expression, the evaluation must be running
+func (d *Debugger) Pause() {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.mode = step
+}
+
+// locals : variables of frame without builtins and without the variables captured by f
+func locals(frame fp.Frame, f *fp.Lambda) fp.Frame {
+	params := make(map[fp.String]bool)
+	var captured fp.Frame
+	if f != nil {
+		captured = f.Frame
+		for _, param := range f.Params {
+			params[param] = true
+		}
+		params[f.Rest] = true
+	}
+	vars := make(fp.Frame)
+	for name, o := range frame {
+		if _, ok := o.(fp.Module); ok {
+			continue
+		}
+		if _, ok := captured[name]; ok && !params[name] {
+			continue
+		}
+		vars[name] = o
+	}
+	return vars
+}
+
+// Locals : variables of the top frame, only while paused
+func (d *Debugger) Locals() fp.Frame {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	var f *fp.Lambda
+	for i := len(d.exprs) - 1; i >= 0; i-- {
+		if d.exprs[i].call != "" {
+			f = &d.exprs[i].lambda
+			break
+		}
+	}
+	return locals(d.r.Stack[len(d.r.Stack)-1], f)
+}
+
+// Globals : variables of the bottom frame without builtins, only while paused
+func (d *Debugger) Globals() fp.Frame {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return locals(d.r.Stack[0], nil)
+}
+
+// Backtrace : lambda calls being evaluated, innermost first, only while paused
+func (d *Debugger) Backtrace() []Call {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	var calls []Call
+	for i := len(d.exprs) - 1; i >= 0; i-- {
+		e := d.exprs[i]
+		if e.call == "" || e.frame >= len(d.r.Stack) {
+			continue
+		}
+		calls = append(calls, Call{
+			Name:   e.call,
+			Expr:   e.expr,
+			Pos:    e.pos,
+			Locals: locals(d.r.Stack[e.frame], &e.lambda),
+		})
+	}
+	return calls
+}
+
+func (d *Debugger) Enter(ctx context.Context, r *fp.Runtime, expr fp.Expr) error {
+	d.mtx.Lock()
+	if d.evaluating {
+		d.mtx.Unlock()
+		return nil
+	}
+	var parent fp.Pos
+	if len(d.exprs) > 0 {
+		parent = d.exprs[len(d.exprs)-1].pos
+	}
+	pos := parent
+	if e, ok := expr.(fp.LambdaExpr); ok && e.Pos.Line > 0 {
+		pos = e.Pos
+	}
+	d.exprs = append(d.exprs, entry{expr: expr, pos: pos})
+	depth := len(d.exprs)
+	pause := d.mode == step ||
+		d.mode == next && depth <= d.depth ||
+		d.mode == out && depth < d.depth
+	var bp *Breakpoint
+	if pos.Line != parent.Line {
+		bp = d.lines[pos.Line]
+	}
+	d.mtx.Unlock()
+	p := Pause{Expr: expr, Pos: pos}
+	var err error
+	switch {
+	case bp != nil:
+		err = d.hit(ctx, r, bp, p)
+	case pause:
+		err = d.pause(ctx, p)
+	}
+	if err != nil {
+		d.mtx.Lock()
+		d.exprs = d.exprs[:len(d.exprs)-1]
+		d.mtx.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (d *Debugger) Call(ctx context.Context, r *fp.Runtime, name fp.String, f fp.Lambda) error {
+	d.mtx.Lock()
+	if d.evaluating || len(d.exprs) == 0 {
+		d.mtx.Unlock()
+		return nil
+	}
+	// arguments are evaluated, the expression on top is the call
+	top := &d.exprs[len(d.exprs)-1]
+	top.call, top.lambda, top.frame = name, f, len(r.Stack)-1
+	p := Pause{Expr: top.expr, Pos: top.pos}
+	bp := d.breakpoints[name]
+	d.mtx.Unlock()
+	if bp == nil {
+		return nil
+	}
+	return d.hit(ctx, r, bp, p)
+}
+
+// hit : pause on bp unless its condition is 0
+func (d *Debugger) hit(ctx context.Context, r *fp.Runtime, bp *Breakpoint, p Pause) error {
+	p.Breakpoint = bp
+	if bp.Condition != nil {
+		d.mtx.Lock()
+		d.evaluating = true
+		d.mtx.Unlock()
+		// the condition is not part of the program, it is never a tail call
+		condCtx, cancel := context.WithCancel(context.Background())
+		stop := context.AfterFunc(ctx, cancel)
+		o, err := r.Step(condCtx, bp.Condition)
+		stop()
+		cancel()
+		d.mtx.Lock()
+		d.evaluating = false
+		d.mtx.Unlock()
+		if err == nil && o == fp.Int(0) {
+			return nil
+		}
+		// a failing condition pauses so that it can be fixed
+		p.Err = err
+	}
+	d.mtx.Lock()
+	bp.Hits++
+	d.mtx.Unlock()
+	return d.pause(ctx, p)
+}
+
+func (d *Debugger) Leave(ctx context.Context, r *fp.Runtime, expr fp.Expr, o fp.Object, err error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if d.evaluating || len(d.exprs) == 0 {
+		return
+	}
+	d.exprs = d.exprs[:len(d.exprs)-1]
+}
+
+// pause : wait for Step, Next, Out or Continue
+func (d *Debugger) pause(ctx context.Context, p Pause) error {
+	d.mtx.Lock()
+	d.mode, d.depth = run, len(d.exprs)
+	d.mtx.Unlock()
+	select {
+	case d.paused <- p:
+	case <-ctx.Done():
+		return fp.InterruptError
+	}
+	select {
+	case m := <-d.resume:
+		d.mtx.Lock()
+		d.mode = m
+		d.mtx.Unlock()
+		return nil
+	case <-ctx.Done():
+		return fp.InterruptError
+	}
+}
+
+func (bp *Breakpoint) String() string {
+	if bp.Name == "" {
+		return fmt.Sprintf("line %d", bp.Line)
+	}
+	return string(bp.Name)
+}
+
+func (p Pause) String() string {
+	switch {
+	case p.Err != nil:
+		return fmt.Sprintf("paused at %s: condition of breakpoint %s failed: %s", p.Expr, p.Breakpoint, p.Err)
+	case p.Breakpoint != nil:
+		return fmt.Sprintf("paused at %s: breakpoint %s", p.Expr, p.Breakpoint)
+	default:
+		return fmt.Sprintf("paused at %s", p.Expr)
+	}
+}
@@ -0,0 +1,187 @@
+package debugger
+
+import (
+	"context"
+	"fp/pkg/fp"
+	"testing"
+	"time"
+)
+
+// session : pauses of the evaluation of source, resumed with actions in order, then "= result"
+func session(t *testing.T, source string, setup func(d *Debugger), actions ...func(d *Debugger)) []string {
+	t.Helper()
+	exprs, err := fp.ParseSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := fp.NewStdRuntime()
+	d := New(r)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan string, 1)
+	go func() {
+		var o fp.Object
+		var err error
+		for _, expr := range exprs {
+			if o, err = r.Step(ctx, expr); err != nil {
+				done <- "error " + err.Error()
+				return
+			}
+		}
+		done <- "= " + o.String()
+	}()
+	setup(d)
+	var events []string
+	for {
+		select {
+		case p := <-d.Paused():
+			events = append(events, p.String())
+			if len(actions) == 0 {
+				t.Fatalf("%s: paused without action left: %v", source, events)
+			}
+			actions[0](d)
+			actions = actions[1:]
+		case result := <-done:
+			return append(events, result)
+		case <-ctx.Done():
+			t.Fatalf("%s: did not end in time: %v", source, events)
+		}
+	}
+}
+
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+const fib = `(let fib (lambda n (case n 0 0 1 1 _ (add (fib (sub n 1)) (fib (sub n 2))))))`
+
+func TestDebugger(t *testing.T) {
+	cond, err := fp.ParseSource(`(case n 1 1 _ 0)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		source  string
+		setup   func(d *Debugger)
+		actions []func(d *Debugger)
+		want    []string
+	}{
+		{
+			name:   "breakpoint",
+			source: fib + `(fib 2)`,
+			setup:  func(d *Debugger) { d.Break("fib", nil) },
+			actions: []func(d *Debugger){
+				(*Debugger).Continue, (*Debugger).Continue, (*Debugger).Continue,
+			},
+			want: []string{
+				"paused at (fib 2): breakpoint fib",
+				"paused at (fib (sub n 1)): breakpoint fib",
+				"paused at (fib (sub n 2)): breakpoint fib",
+				"= 1",
+			},
+		},
+		{
+			name:    "condition",
+			source:  fib + `(fib 3)`,
+			setup:   func(d *Debugger) { d.Break("fib", cond[0]) },
+			actions: []func(d *Debugger){(*Debugger).Continue, (*Debugger).Continue},
+			want: []string{
+				// (fib 1) in (fib 2), then in (fib 3)
+				"paused at (fib (sub n 1)): breakpoint fib",
+				"paused at (fib (sub n 2)): breakpoint fib",
+				"= 2",
+			},
+		},
+		{
+			name:   "step",
+			source: `(let f (lambda x (mul x 2))) (add 1 (f 3))`,
+			setup:  func(d *Debugger) { d.Break("f", nil) },
+			actions: []func(d *Debugger){
+				(*Debugger).Step, (*Debugger).Step, (*Debugger).Step, (*Debugger).Continue,
+			},
+			want: []string{
+				"paused at (f 3): breakpoint f",
+				"paused at (mul x 2)",
+				"paused at x",
+				"paused at 2",
+				"= 7",
+			},
+		},
+		{
+			name:   "next",
+			source: `(let f (lambda x (tail (add x 1) (mul x 2)))) (add 1 (f 3))`,
+			setup:  func(d *Debugger) { d.Break("f", nil) },
+			actions: []func(d *Debugger){
+				(*Debugger).Step, (*Debugger).Step, (*Debugger).Next, (*Debugger).Continue,
+			},
+			want: []string{
+				"paused at (f 3): breakpoint f",
+				"paused at (tail (add x 1) (mul x 2))",
+				"paused at (add x 1)",
+				"paused at (mul x 2)",
+				"= 7",
+			},
+		},
+		{
+			name:   "out",
+			source: `(let f (lambda x (mul x 2))) (add (f 3) 1)`,
+			setup:  func(d *Debugger) { d.Break("f", nil) },
+			actions: []func(d *Debugger){
+				(*Debugger).Step, (*Debugger).Out, (*Debugger).Continue,
+			},
+			want: []string{
+				"paused at (f 3): breakpoint f",
+				"paused at (mul x 2)",
+				"paused at 1",
+				"= 7",
+			},
+		},
+		{
+			name:    "clear",
+			source:  fib + `(fib 3)`,
+			setup:   func(d *Debugger) { d.Break("fib", nil) },
+			actions: []func(d *Debugger){func(d *Debugger) { d.Clear("fib"); d.Continue() }},
+			want: []string{
+				"paused at (fib 3): breakpoint fib",
+				"= 2",
+			},
+		},
+	}
+	for _, test := range tests {
+		got := session(t, test.source, test.setup, test.actions...)
+		if !equal(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDebuggerLocals(t *testing.T) {
+	var locals fp.Frame
+	var backtrace []Call
+	got := session(t, fib+`(let a 5) (fib 2)`, func(d *Debugger) { d.Break("fib", nil) },
+		(*Debugger).Continue,
+		func(d *Debugger) {
+			locals, backtrace = d.Locals(), d.Backtrace()
+			d.Continue()
+		},
+		(*Debugger).Continue,
+	)
+	if got[len(got)-1] != "= 1" {
+		t.Errorf("got %q", got)
+	}
+	if len(locals) != 1 || locals["n"] != fp.Int(1) {
+		t.Errorf("locals: got %v, want n 1", locals)
+	}
+	if len(backtrace) != 2 || backtrace[0].Locals["n"] != fp.Int(1) || backtrace[1].Locals["n"] != fp.Int(2) {
+		t.Errorf("backtrace: got %v, want fib with n 1 then n 2", backtrace)
+	}
+}
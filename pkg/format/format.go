@@ -0,0 +1,108 @@
+package format
+
+import (
+	"fmt"
+	"fp/pkg/fp"
+	"strings"
+)
+
+// indentWidth : spaces per open parenthesis, like example.lisp
+const indentWidth = 4
+
+// Indent : indent every line by 4 spaces per parenthesis open at its start, a line starting with ) is aligned with its (
+//
+// line breaks, comments and spacing inside lines are kept, lines inside a string are not changed
+func Indent(source string) string {
+	tokens, posList := fp.TokenizePos(source)
+	lines := strings.Split(source, "\n")
+	depth := make([]int, len(lines))     // open parentheses at the start of the line
+	closing := make([]bool, len(lines))  // the line starts with )
+	verbatim := make([]bool, len(lines)) // the line starts inside a string
+	d, t := 0, 0
+	for i := range lines {
+		for ; t < len(tokens) && posList[t].Line <= i; t++ {
+			switch tokens[t] {
+			case "(":
+				d++
+			case ")":
+				d = max(d-1, 0)
+			default:
+				for k := 1; k <= strings.Count(tokens[t], "\n"); k++ {
+					verbatim[posList[t].Line-1+k] = true
+				}
+			}
+		}
+		depth[i] = d
+		closing[i] = t < len(tokens) && posList[t].Line == i+1 && tokens[t] == ")"
+	}
+	for i, line := range lines {
+		if verbatim[i] {
+			continue
+		}
+		line = strings.TrimLeft(line, " \t")
+		if i+1 == len(lines) || !verbatim[i+1] {
+			// trailing spaces of a line followed by a string line are part of the string
+			line = strings.TrimRight(line, " \t\r")
+		}
+		if line == "" {
+			lines[i] = ""
+			continue
+		}
+		level := depth[i]
+		if closing[i] {
+			level = max(level-1, 0)
+		}
+		lines[i] = strings.Repeat(" ", level*indentWidth) + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Source : canonical formatting of a whole source file, comments are kept
+//
+// a call is printed on one line if it fits in 80 columns and has no comment inside, otherwise its arguments go on their own lines,
+// indented by 4 spaces, and its ) on its own line. let and case keep their first argument on the line of the name,
+// lambda and defmacro every argument but the body, case puts each pattern with its result, and case and tail never fit on one line
+// with more than one branch or expression. comments after code are aligned, at most one empty line is kept between expressions
+func Source(source string) (string, error) {
+	formatted, err := format(source)
+	if err != nil {
+		return "", err
+	}
+	// the formatted source must be the same program and be formatted the same way again
+	before, err := fp.ParseSource(source)
+	if err != nil {
+		return "", err
+	}
+	after, err := fp.ParseSource(formatted)
+	if err != nil || len(after) != len(before) {
+		return "", fmt.Errorf("format: internal error: the formatted source is a different program")
+	}
+	for i := range before {
+		if before[i].String() != after[i].String() {
+			return "", fmt.Errorf("format: internal error: the formatted source is a different program at %s", before[i])
+		}
+	}
+	if again, err := format(formatted); err != nil || again != formatted {
+		return "", fmt.Errorf("format: internal error: formatting is not idempotent")
+	}
+	return formatted, nil
+}
+
+func format(source string) (string, error) {
+	nodes, comments, err := parseTree(source)
+	if err != nil {
+		return "", err
+	}
+	p := &printer{}
+	for _, n := range nodes {
+		if len(p.lines) > 0 {
+			if n.blank {
+				p.blankLine()
+			}
+			p.broken = true
+		}
+		p.node(n)
+	}
+	p.ownLines(comments)
+	return p.String(), nil
+}
@@ -0,0 +1,126 @@
+package format
+
+import (
+	"fp/pkg/fp"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "short call on one line",
+			source: "(let   f (lambda x y\n  (add x y)))",
+			want:   "(let f (lambda x y (add x y)))\n",
+		},
+		{
+			name:   "comments kept and aligned",
+			source: "// header\n(let f 1) // one\n(f 1 2)   // call\n",
+			want:   "// header\n(let f 1) // one\n(f 1 2)   // call\n",
+		},
+		{
+			name:   "comment inside a call",
+			source: "(let x (list 1 2\n  // inside\n  3))",
+			want:   "(let x\n    (list\n        1\n        2\n        // inside\n        3\n    )\n)\n",
+		},
+		{
+			name:   "case puts each pattern with its result",
+			source: `(let g (lambda x (case x 1 "one" 2 "two" _ "many")))`,
+			want:   "(let g\n    (lambda x\n        (case x\n            1 \"one\"\n            2 \"two\"\n            _ \"many\"\n        )\n    )\n)\n",
+		},
+		{
+			name:   "case with one branch fits on one line",
+			source: "(case x\n  _ 1)",
+			want:   "(case x _ 1)\n",
+		},
+		{
+			name:   "tail with several expressions is broken",
+			source: "(let h (lambda x (tail (print x) (add x 1))))",
+			want:   "(let h\n    (lambda x\n        (tail\n            (print x)\n            (add x 1)\n        )\n    )\n)\n",
+		},
+		{
+			name:   "lambda keeps its parameters on the line of the name",
+			source: "(let long (lambda aaaaaaaaaa bbbbbbbbbb cccccccccc (add aaaaaaaaaa bbbbbbbbbb cccccccccc aaaaaaaaaa bbbbbbbbbb)))",
+			want:   "(let long\n    (lambda aaaaaaaaaa bbbbbbbbbb cccccccccc\n        (add aaaaaaaaaa bbbbbbbbbb cccccccccc aaaaaaaaaa bbbbbbbbbb)\n    )\n)\n",
+		},
+		{
+			name:   "at most one empty line between expressions",
+			source: "(let a 1)\n\n\n\n(let b 2)\n(let c 3)",
+			want:   "(let a 1)\n\n(let b 2)\n(let c 3)\n",
+		},
+	}
+	for _, test := range tests {
+		got, err := Source(test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSourceIdempotent(t *testing.T) {
+	sources := []string{
+		"(let f (lambda x (case (sign x) 1 (tail (print x) (f (sub x 1))) _ 0))) // countdown",
+		"(try (div 1 0) (catch e (peek e \"message\")) (finally (print \"done\")))",
+		"(defmacro swap! a b `(with (tmp# ,a) (tail (let ,a ,b) (let ,b tmp#))))",
+		"(print \"a string\nover two lines\" 1)\n// last comment",
+	}
+	for _, source := range sources {
+		once, err := Source(source)
+		if err != nil {
+			t.Errorf("%s: %v", source, err)
+			continue
+		}
+		twice, err := Source(once)
+		if err != nil {
+			t.Errorf("%s: %v", once, err)
+			continue
+		}
+		if twice != once {
+			t.Errorf("format(format(x)) != format(x) for %s:\n%s\n%s", source, once, twice)
+		}
+	}
+}
+
+// TestSourceExample : example.lisp is the same program with the same comments once formatted
+func TestSourceExample(t *testing.T) {
+	source, err := os.ReadFile("../../example.lisp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := Source(string(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := fp.ParseSource(string(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := fp.ParseSource(formatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("%d expressions once formatted, want %d", len(after), len(before))
+	}
+	for i := range before {
+		if after[i].String() != before[i].String() {
+			t.Errorf("expression %d: got %s, want %s", i, after[i], before[i])
+		}
+	}
+	comment := regexp.MustCompile(`//[^\n]*`)
+	if got, want := comment.FindAllString(formatted, -1), comment.FindAllString(string(source), -1); len(got) != len(want) {
+		t.Errorf("%d comments once formatted, want %d", len(got), len(want))
+	}
+	if again, err := Source(formatted); err != nil || again != formatted {
+		t.Errorf("formatted example.lisp changes when formatted again: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package format
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxWidth : a call is printed on one line if it fits in maxWidth columns
+const maxWidth = 80
+
+// form : layout of a call that does not fit on one line
+type form struct {
+	header int  // arguments kept on the line of the name, -1 for every argument but the last
+	pairs  bool // the body is pattern result pairs, like case
+	always bool // never printed on one line if there is more than one body line
+}
+
+// forms : other calls print every argument on its own line
+var forms = map[string]form{
+	"let":      {header: 1},
+	"lambda":   {header: -1},
+	"defmacro": {header: -1},
+	"case":     {header: 1, pairs: true, always: true},
+	"tail":     {header: 0, always: true},
+}
+
+// line : output line, the trailing comment is aligned with the ones of the next lines
+type line struct {
+	indent  int
+	code    string
+	comment string
+}
+
+type printer struct {
+	lines  []line
+	indent int  // of the next line
+	broken bool // the current line ends with a comment, the next code goes on a new line
+}
+
+func (p *printer) newline() {
+	p.lines = append(p.lines, line{indent: p.indent})
+	p.broken = false
+}
+
+func (p *printer) current() *line {
+	if len(p.lines) == 0 || p.broken {
+		p.newline()
+	}
+	return &p.lines[len(p.lines)-1]
+}
+
+// column : where the next code starts on the current line
+func (p *printer) column() int {
+	l := p.current()
+	if i := strings.LastIndex(l.code, "\n"); i >= 0 {
+		return utf8.RuneCountInString(l.code[i+1:])
+	}
+	return l.indent + utf8.RuneCountInString(l.code)
+}
+
+func (p *printer) write(s string) {
+	l := p.current()
+	l.code += s
+}
+
+// space : separate the next code from the previous one on the same line
+func (p *printer) space() {
+	if l := p.current(); l.code != "" {
+		l.code += " "
+	}
+}
+
+func (p *printer) trailing(comment string) {
+	if comment == "" {
+		return
+	}
+	p.current().comment = comment
+	p.broken = true
+}
+
+// ownLines : comments on their own lines, then the next code on a new line
+func (p *printer) ownLines(comments []comment) {
+	for _, c := range comments {
+		if c.blank {
+			p.blankLine()
+		}
+		if p.current().code != "" {
+			p.newline()
+		}
+		p.write(c.text)
+		p.broken = true
+	}
+}
+
+// blankLine : one empty line, never at the beginning
+func (p *printer) blankLine() {
+	if len(p.lines) == 0 {
+		return
+	}
+	if l := p.lines[len(p.lines)-1]; l.code == "" && l.comment == "" {
+		return
+	}
+	p.lines = append(p.lines, line{})
+	p.broken = true
+}
+
+// flat : the node on one line, ok is false if a comment or a form prevents it
+func flat(n *node) (string, bool) {
+	if n.atom != "" {
+		return n.prefix + n.atom, true
+	}
+	if len(n.closing) > 0 {
+		return "", false
+	}
+	if f, ok := forms[head(n)]; ok && f.always && len(body(n, f)) > 1 {
+		return "", false
+	}
+	parts := make([]string, len(n.children))
+	for i, child := range n.children {
+		if len(child.comments) > 0 || (child.trailing != "" && i < len(n.children)-1) {
+			return "", false
+		}
+		s, ok := flat(child)
+		if !ok {
+			return "", false
+		}
+		parts[i] = s
+	}
+	// the trailing comment of the last child ends the line after )
+	if len(n.children) > 0 && n.children[len(n.children)-1].trailing != "" {
+		return "", false
+	}
+	return n.prefix + "(" + strings.Join(parts, " ") + ")", true
+}
+
+// head : name of the call, "" if it is not a name
+func head(n *node) string {
+	if n.atom != "" || len(n.children) == 0 || n.children[0].atom == "" || n.children[0].prefix != "" {
+		return ""
+	}
+	return n.children[0].atom
+}
+
+// header : number of arguments on the line of the name
+func header(n *node, f form) int {
+	args := len(n.children) - 1
+	if f.header < 0 {
+		return max(args-1, 0)
+	}
+	return min(f.header, args)
+}
+
+// body : arguments on their own lines, pairs are counted once
+func body(n *node, f form) [][]*node {
+	var lines [][]*node
+	args := n.children[1+header(n, f):]
+	for len(args) > 0 {
+		k := 1
+		if f.pairs && len(args) >= 2 {
+			k = 2
+		}
+		lines = append(lines, args[:k])
+		args = args[k:]
+	}
+	return lines
+}
+
+func (p *printer) node(n *node) {
+	p.ownLines(n.comments)
+	if s, ok := flat(n); ok && p.column()+utf8.RuneCountInString(s) <= maxWidth {
+		p.write(s)
+		p.trailing(n.trailing)
+		return
+	}
+	base := p.current().indent
+	p.write(n.prefix + "(")
+	if len(n.children) > 0 {
+		f := forms[head(n)]
+		if head(n) == "" {
+			f = form{}
+		}
+		first := n.children[0]
+		p.node(first)
+		for _, arg := range n.children[1 : 1+header(n, f)] {
+			p.space()
+			p.node(arg)
+		}
+		saved := p.indent
+		p.indent = base + indentWidth
+		for _, args := range body(n, f) {
+			if args[0].blank {
+				p.blankLine()
+			}
+			p.newline()
+			for i, arg := range args {
+				if i > 0 {
+					p.space()
+				}
+				p.node(arg)
+			}
+		}
+		p.ownLines(n.closing)
+		p.indent = base
+		p.newline()
+		p.indent = saved
+	}
+	p.write(")")
+	p.trailing(n.trailing)
+}
+
+// String : lines with the trailing comments of consecutive lines aligned
+func (p *printer) String() string {
+	b := &strings.Builder{}
+	for i := 0; i < len(p.lines); {
+		// run of lines with a trailing comment
+		j, width := i, 0
+		for ; j < len(p.lines) && p.lines[j].comment != ""; j++ {
+			width = max(width, p.lines[j].width())
+		}
+		if j == i {
+			j = i + 1
+		}
+		for _, l := range p.lines[i:j] {
+			s := ""
+			if l.code != "" {
+				s = strings.Repeat(" ", l.indent) + l.code
+			}
+			if l.comment != "" {
+				s += strings.Repeat(" ", width-l.width()+1) + l.comment
+			}
+			b.WriteString(s + "\n")
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// width : columns of the code of the last line of l
+func (l line) width() int {
+	if i := strings.LastIndex(l.code, "\n"); i >= 0 {
+		return utf8.RuneCountInString(l.code[i+1:])
+	}
+	return l.indent + utf8.RuneCountInString(l.code)
+}
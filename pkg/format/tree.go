@@ -0,0 +1,98 @@
+package format
+
+import (
+	"fmt"
+	"fp/pkg/fp"
+	"strings"
+)
+
+// comment : comment on its own line, blank if an empty line is before it
+type comment struct {
+	text  string
+	blank bool
+}
+
+// node : atom or list of the source with the comments around it
+type node struct {
+	atom     fp.Token // "" for a list
+	prefix   fp.Token // ` , or ,@ before the node
+	children []*node
+	blank    bool      // an empty line is before the node, or before its comments
+	comments []comment // own line comments before the node
+	trailing string    // comment after the node on the same line
+	closing  []comment // own line comments before ) of a list
+}
+
+// parseTree : nodes of the source, with comments after the last node
+func parseTree(source string) ([]*node, []comment, error) {
+	tokens, posList := fp.TokenizeComments(source)
+	root := &node{}
+	stack := []*node{root}
+	var pending []comment // own line comments before the next node
+	var prefix fp.Token
+	var last *node // node ending on lastLine, a comment on that line trails it
+	lastLine := 0  // line of the end of the previous token
+	blank := func(line int) bool {
+		return lastLine > 0 && line > lastLine+1
+	}
+	for i, token := range tokens {
+		pos := posList[i]
+		top := stack[len(stack)-1]
+		switch {
+		case strings.HasPrefix(token, "//"):
+			text := strings.TrimRight(token, " \t\r")
+			if last != nil && pos.Line == lastLine && prefix == "" {
+				last.trailing = text
+			} else {
+				pending = append(pending, comment{text: text, blank: blank(pos.Line)})
+			}
+			lastLine = pos.Line
+			continue
+		case token == ")":
+			if len(stack) == 1 {
+				return nil, nil, fmt.Errorf("%s: unexpected )", pos)
+			}
+			if prefix != "" {
+				return nil, nil, fmt.Errorf("%s: %s before )", pos, prefix)
+			}
+			top.closing, pending = pending, nil
+			stack = stack[:len(stack)-1]
+			last = top
+		case token == "`" || token == "," || token == ",@":
+			if prefix != "" {
+				// nested prefixes are kept together, like ``x
+				prefix += token
+				continue
+			}
+			prefix = token
+			lastLine = pos.Line
+			continue
+		default:
+			if top != root && len(top.children) == 0 && len(pending) > 0 {
+				// comments between ( and the name are printed before (
+				top.comments, pending = append(top.comments, pending...), nil
+			}
+			n := &node{prefix: prefix, comments: pending, blank: blank(pos.Line)}
+			if len(pending) > 0 {
+				n.blank = pending[0].blank
+			}
+			pending, prefix = nil, ""
+			top.children = append(top.children, n)
+			if token == "(" {
+				stack = append(stack, n)
+				last = nil
+			} else {
+				n.atom = token
+				last = n
+			}
+		}
+		lastLine = pos.Line + strings.Count(token, "\n")
+	}
+	if len(stack) > 1 {
+		return nil, nil, fmt.Errorf("missing )")
+	}
+	if prefix != "" {
+		return nil, nil, fmt.Errorf("%s at the end of the source", prefix)
+	}
+	return root.children, pending, nil
+}
@@ -2,73 +2,125 @@ package fp
 
 import (
 	"fmt"
-	"strings"
 	"unicode"
 )
 
 type Token = string
 
-func removeComments(str string) string {
-	lines := strings.Split(str, "\n")
-	var newLines []string
-	for _, line := range lines {
-		newLines = append(newLines, strings.Split(line, "//")[0])
-	}
-	return strings.Join(newLines, "\n")
+// Pos : position of a token in the source, line and column start at 1, zero if unknown
+type Pos struct {
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
 }
 
 func Tokenize(str string) []Token {
-	str = removeComments(str)
+	tokens, _ := TokenizePos(str)
+	return tokens
+}
 
+// TokenizePos : Tokenize with the position of the first character of every token
+func TokenizePos(str string) ([]Token, []Pos) {
+	return tokenize(str, false)
+}
+
+// TokenizeComments : TokenizePos keeping comments as tokens starting with //, until the end of the line
+func TokenizeComments(str string) ([]Token, []Pos) {
+	return tokenize(str, true)
+}
+
+func tokenize(str string, comments bool) ([]Token, []Pos) {
 	const (
 		STATE_OUTSTRING = iota
 		STATE_INSTRING
 		STATE_INSTRING_ESCAPE
+		STATE_COMMENT
 	)
 
 	var tokens []Token
+	var posList []Pos
 	state := STATE_OUTSTRING
 	buffer := ""
+	var start, cur Pos // start of buffer, position of ch
 	flushBuffer := func() {
 		if len(buffer) > 0 {
 			tokens = append(tokens, buffer)
+			posList = append(posList, start)
 		}
 		buffer = ""
 	}
-	for _, ch := range str {
+	add := func(s string) {
+		if len(buffer) == 0 {
+			start = cur
+		}
+		buffer += s
+	}
+	runes := []rune(str)
+	cur = Pos{Line: 1, Col: 0}
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		if i > 0 && runes[i-1] == '\n' {
+			cur = Pos{Line: cur.Line + 1, Col: 1}
+		} else {
+			cur.Col++
+		}
 		switch state {
 		case STATE_OUTSTRING:
-			if unicode.IsSpace(ch) {
+			if ch == '/' && i+1 < len(runes) && runes[i+1] == '/' {
+				// comment until end of line, "//" inside a string is not a comment
+				flushBuffer()
+				if comments {
+					add(string(ch))
+				}
+				state = STATE_COMMENT
+			} else if unicode.IsSpace(ch) {
+				flushBuffer()
+			} else if ch == ',' && i+1 < len(runes) && runes[i+1] == '@' {
+				// unquote-splicing
+				flushBuffer()
+				add(",@")
 				flushBuffer()
-			} else if ch == '(' || ch == ')' || ch == '*' {
+				i++
+				cur.Col++
+			} else if ch == '(' || ch == ')' || ch == '*' || ch == '`' || ch == ',' {
 				flushBuffer()
-				buffer += string(ch)
+				add(string(ch))
 				flushBuffer()
 			} else if ch == '"' {
 				flushBuffer()
-				buffer += string(ch)
+				add(string(ch))
 				state = STATE_INSTRING
 			} else {
-				buffer += string(ch)
+				add(string(ch))
 			}
 		case STATE_INSTRING:
 			if ch == '\\' {
-				buffer += string(ch)
+				add(string(ch))
 				state = STATE_INSTRING_ESCAPE
 			} else if ch == '"' {
-				buffer += string(ch)
+				add(string(ch))
 				flushBuffer()
 				state = STATE_OUTSTRING
 			} else {
-				buffer += string(ch)
+				add(string(ch))
 			}
 		case STATE_INSTRING_ESCAPE:
-			buffer += string(ch)
+			add(string(ch))
 			state = STATE_INSTRING
+		case STATE_COMMENT:
+			if ch == '\n' {
+				flushBuffer()
+				state = STATE_OUTSTRING
+			} else if comments {
+				add(string(ch))
+			}
 		default:
 			panic(fmt.Sprintf("invalid state: %d", state))
 		}
 	}
 	flushBuffer()
-	return tokens
+	return tokens, posList
 }
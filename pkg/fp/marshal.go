@@ -0,0 +1,172 @@
+package fp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Marshal : convert a go value into an fp object
+//
+// integers, bool, string, slices, maps and structs are supported, structs become a Dict with String keys,
+// field names can be changed with a struct tag similar to encoding/json
+//
+//	Name  string `fp:"name"`           // key "name"
+//	Port  int    `fp:"port,omitempty"` // no key if Port is zero
+//	Token string `fp:"-"`              // never converted
+func Marshal(v any) (Object, error) {
+	o, err := toObject(nil, reflect.ValueOf(v))
+	if err != nil {
+		return nil, fmt.Errorf("fp: marshal: %w", err)
+	}
+	return o, nil
+}
+
+// Unmarshal : convert an fp object into the go value pointed by v, see Marshal
+//
+// unmarshalling into an empty interface gives int, string, []any and map[string]any (map[any]any if a key is not a String),
+// Lambda and Module cannot be unmarshalled since they need a runtime to be called
+func Unmarshal(o Object, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("fp: unmarshal: expected a non-nil pointer, got %T", v)
+	}
+	e, err := fromObject(nil, o, rv.Type().Elem())
+	if err != nil {
+		return fmt.Errorf("fp: unmarshal: %w", err)
+	}
+	rv.Elem().Set(e)
+	return nil
+}
+
+type structField struct {
+	name      String
+	index     []int
+	omitEmpty bool
+}
+
+// structFields : exported fields of a struct type, embedded structs without tag are flattened
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("fp")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+			for _, embedded := range structFields(f.Type) {
+				embedded.index = append([]int{i}, embedded.index...)
+				fields = append(fields, embedded)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{
+			name:      String(name),
+			index:     []int{i},
+			omitEmpty: opts == "omitempty",
+		})
+	}
+	return fields
+}
+
+func structToDict(ec ExtensionContext, v reflect.Value) (Object, error) {
+	d := make(Dict)
+	for _, field := range structFields(v.Type()) {
+		fv := v.FieldByIndex(field.index)
+		if field.omitEmpty && fv.IsZero() {
+			continue
+		}
+		o, err := toObject(ec, fv)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.name, err)
+		}
+		d[field.name] = o
+	}
+	return d, nil
+}
+
+func dictToStruct(ec ExtensionContext, d Dict, v reflect.Value) error {
+	for _, field := range structFields(v.Type()) {
+		o, ok := d[field.name]
+		if !ok {
+			continue
+		}
+		fv, err := fromObject(ec, o, v.FieldByIndex(field.index).Type())
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.name, err)
+		}
+		v.FieldByIndex(field.index).Set(fv)
+	}
+	return nil
+}
+
+// fromObjectToAny : convert an fp object into natural go values for an empty interface type t
+func fromObjectToAny(ec ExtensionContext, o Object, t reflect.Type) (reflect.Value, error) {
+	var natural any
+	switch o := o.(type) {
+	case nil:
+		return reflect.Zero(t), nil
+	case Int:
+		natural = int(o)
+	case String:
+		natural = string(o)
+	case List:
+		l := make([]any, 0, len(o))
+		for i, elem := range o {
+			e, err := fromObjectToAny(ec, elem, t)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i+1, err)
+			}
+			l = append(l, e.Interface())
+		}
+		natural = l
+	case Dict:
+		stringKeys := true
+		for k := range o {
+			if _, ok := k.(String); !ok {
+				stringKeys = false
+			}
+		}
+		if stringKeys {
+			m := make(map[string]any, len(o))
+			for k, elem := range o {
+				e, err := fromObjectToAny(ec, elem, t)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("value of %s: %w", k, err)
+				}
+				m[string(k.(String))] = e.Interface()
+			}
+			natural = m
+		} else {
+			m := make(map[any]any, len(o))
+			for k, elem := range o {
+				key, err := fromObjectToAny(ec, k, t)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("key %s: %w", k, err)
+				}
+				e, err := fromObjectToAny(ec, elem, t)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("value of %s: %w", k, err)
+				}
+				m[key.Interface()] = e.Interface()
+			}
+			natural = m
+		}
+	default:
+		if ec == nil {
+			return reflect.Value{}, errors.New("cannot unmarshal " + string(getType(o)) + " into go value")
+		}
+		// keep objects which only make sense with a runtime
+		natural = o
+	}
+	return reflect.ValueOf(&natural).Elem().Convert(t), nil
+}
@@ -0,0 +1,112 @@
+package fp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type marshalConfig struct {
+	Name  string `fp:"name"`
+	Port  int    `fp:"port,omitempty"`
+	Token string `fp:"-"`
+	Tags  []string
+	marshalEmbedded
+}
+
+type marshalEmbedded struct {
+	Debug bool `fp:"debug"`
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string // String of the Object, only checked for values printed in a stable order
+	}{
+		{value: 3, want: "3"},
+		{value: uint16(7), want: "7"},
+		{value: true, want: "1"},
+		{value: "abc", want: "abc"},
+		{value: []int{1, 2, 3}, want: "[1,2,3,]"},
+		{value: [][]string{{"a"}, {}}, want: "[[a,],[],]"},
+		{value: map[string]int{"a": 1}},
+		{value: map[int][]int{1: {2}}},
+		{value: marshalConfig{Name: "fp", Port: 8080, Tags: []string{"x"}, marshalEmbedded: marshalEmbedded{Debug: true}}},
+	}
+	for _, test := range tests {
+		o, err := Marshal(test.value)
+		if err != nil {
+			t.Errorf("Marshal(%v): %v", test.value, err)
+			continue
+		}
+		if test.want != "" && o.String() != test.want {
+			t.Errorf("Marshal(%v): got %s, want %s", test.value, o, test.want)
+		}
+		got := reflect.New(reflect.TypeOf(test.value))
+		if err := Unmarshal(o, got.Interface()); err != nil {
+			t.Errorf("Unmarshal(%s): %v", o, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.Elem().Interface(), test.value) {
+			t.Errorf("round trip of %v: got %v", test.value, got.Elem().Interface())
+		}
+	}
+}
+
+func TestMarshalTags(t *testing.T) {
+	o, err := Marshal(marshalConfig{Name: "fp", Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := o.(Dict)
+	for _, key := range []String{"name", "Tags", "debug"} {
+		if _, ok := d[key]; !ok {
+			t.Errorf("key %s is missing in %s", key, o)
+		}
+	}
+	for _, key := range []String{"port", "Port", "Token", "marshalEmbedded"} {
+		if _, ok := d[key]; ok {
+			t.Errorf("key %s should not be in %s", key, o)
+		}
+	}
+}
+
+func TestUnmarshalAny(t *testing.T) {
+	var v any
+	if err := Unmarshal(List{Int(1), String("a"), Dict{String("k"): List{}}}, &v); err != nil {
+		t.Fatal(err)
+	}
+	want := []any{1, "a", map[string]any{"k": []any{}}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got %#v, want %#v", v, want)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	var n int
+	var u uint8
+	var s []string
+	var f func()
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unmarshal into a non-pointer", Unmarshal(Int(1), n), "fp: unmarshal: expected a non-nil pointer, got int"},
+		{"unmarshal a String into int", Unmarshal(String("a"), &n), "fp: unmarshal: expected Int, got String"},
+		{"unmarshal an overflow", Unmarshal(Int(300), &u), "fp: unmarshal: 300 overflows uint8"},
+		{"unmarshal a wrong element", Unmarshal(List{String("a"), Int(1)}, &s), "fp: unmarshal: element 2: expected String, got Int"},
+		{"unmarshal a lambda", Unmarshal(Lambda{}, &f), "fp: unmarshal: cannot convert Lambda into func() without runtime"},
+	}
+	for _, test := range tests {
+		if test.err == nil || test.err.Error() != test.want {
+			t.Errorf("%s: got error %v, want %q", test.name, test.err, test.want)
+		}
+	}
+	if _, err := Marshal(func() {}); err == nil || !strings.HasPrefix(err.Error(), "fp: marshal: cannot convert func()") {
+		t.Errorf("marshal a func: got error %v", err)
+	}
+	if _, err := Marshal(map[string]float64{"a": 0.5}); err == nil || err.Error() != "fp: marshal: value of a: 0.5 cannot be represented as Int" {
+		t.Errorf("marshal a float: got error %v", err)
+	}
+}
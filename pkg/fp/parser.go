@@ -2,6 +2,7 @@ package fp
 
 import (
 	"errors"
+	"fmt"
 )
 
 // Expr : union of NameExpr, LambdaExpr
@@ -23,6 +24,7 @@ func (e NameExpr) MustTypeExpr() {
 type LambdaExpr struct {
 	Name NameExpr
 	Args []Expr
+	Pos  Pos // position of "(" in the source, zero if unknown, see ParseSource
 }
 
 func (e LambdaExpr) String() string {
@@ -40,9 +42,12 @@ func (e LambdaExpr) MustTypeExpr() {
 
 }
 
+// errEmptyTokenList : the expression is not closed yet
+var errEmptyTokenList = errors.New("empty token list")
+
 func pop(tokenList []Token) ([]Token, Token, error) {
 	if len(tokenList) == 0 {
-		return nil, "", errors.New("empty token list")
+		return nil, "", errEmptyTokenList
 	}
 	return tokenList[1:], tokenList[0], nil
 }
@@ -62,6 +67,44 @@ func ParseAll(tokenList []Token) ([]Expr, []Token) {
 	return exprList, tokenList
 }
 
+// ParseError : error of ParseSource at Pos
+type ParseError struct {
+	Pos Pos
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: parse error: %s", e.Pos, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseSource : parse a whole source file, every LambdaExpr has its position
+//
+// on error, the expressions before the failing one are returned with a *ParseError
+func ParseSource(source string) ([]Expr, error) {
+	tokenList, posList := TokenizePos(source)
+	var exprList []Expr
+	for len(tokenList) > 0 {
+		pos := posList[len(posList)-len(tokenList)]
+		expr, rest, err := parsePos(tokenList, posList)
+		if err != nil {
+			switch {
+			case tokenList[0] == ")":
+				err = errors.New("unexpected )")
+			case errors.Is(err, errEmptyTokenList):
+				err = errors.New("missing )")
+			}
+			return exprList, &ParseError{Pos: pos, Err: err}
+		}
+		exprList = append(exprList, expr)
+		tokenList = rest
+	}
+	return exprList, nil
+}
+
 type Parser struct {
 	Buffer []Token
 }
@@ -85,12 +128,30 @@ func (p *Parser) Input(tok Token) Expr {
 	}
 }
 
+var prefixForms = map[Token]NameExpr{
+	"`":  "quasiquote",
+	",":  "unquote",
+	",@": "unquote-splicing",
+}
+
 func parseSingle(tokenList []Token) (Expr, []Token, error) {
+	return parsePos(tokenList, nil)
+}
+
+// parsePos : parseSingle, posList is the position of every token of the source, or nil
+func parsePos(tokenList []Token, posList []Pos) (Expr, []Token, error) {
+	position := func(tokenList []Token) Pos {
+		if posList == nil || len(tokenList) == 0 {
+			return Pos{}
+		}
+		return posList[len(posList)-len(tokenList)]
+	}
 	var parse func(tokenList []Token) (Expr, []Token, bool, error)
 	parse = func(tokenList []Token) (Expr, []Token, bool, error) {
 		if len(tokenList) == 0 {
-			return nil, nil, false, errors.New("empty token list")
+			return nil, nil, false, errEmptyTokenList
 		}
+		pos := position(tokenList)
 		tokenList, head, err := pop(tokenList) // pop ( or [ or name
 		if err != nil {
 			return nil, nil, false, err
@@ -121,6 +182,20 @@ func parseSingle(tokenList []Token) (Expr, []Token, error) {
 			return LambdaExpr{
 				Name: NameExpr(funcName),
 				Args: exprList,
+				Pos:  pos,
+			}, tokenList, false, nil
+		case "`", ",", ",@": // `x ,x ,@x are read as (quasiquote x) (unquote x) (unquote-splicing x)
+			expr, tokenList, endWithClose, err := parse(tokenList)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			if endWithClose {
+				return nil, nil, false, errors.New("parse error")
+			}
+			return LambdaExpr{
+				Name: prefixForms[head],
+				Args: []Expr{expr},
+				Pos:  pos,
 			}, tokenList, false, nil
 		default:
 			return NameExpr(head), tokenList, head == ")", nil
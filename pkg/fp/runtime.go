@@ -3,11 +3,17 @@ package fp
 import (
 	"encoding/json"
 	"errors"
+	"os"
 	"strconv"
+	"sync"
 )
 
 // NewCoreRuntime - runtime + core control flow extensions
-func NewCoreRuntime() *Runtime {
+func NewCoreRuntime(opts ...Option) *Runtime {
+	return newCoreRuntime().apply(opts...)
+}
+
+func newCoreRuntime() *Runtime {
 	return (&Runtime{
 		parseLiteral: func(lit String) (Object, error) {
 			if len(lit) == 0 {
@@ -32,16 +38,36 @@ func NewCoreRuntime() *Runtime {
 		Stack: []Frame{
 			make(Frame),
 		},
+		Options: defaultOptions(),
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+		Stdin:   os.Stdin,
+		values:  &sync.Map{},
 	}).
 		LoadModule(letModule).
 		LoadModule(delModule).
+		LoadModule(withModule).
+		LoadModule(letrecModule).
+		LoadModule(quoteModule).
+		LoadModule(quasiquoteModule).
+		LoadModule(defmacroModule).
+		LoadModule(macroexpandModule).
+		LoadExtension(gensymExtension).
+		LoadExtension(exprNameExtension).
+		LoadExtension(exprArgsExtension).
 		LoadModule(lambdaModule).
-		LoadModule(caseModule)
+		LoadModule(caseModule).
+		LoadModule(tryModule).
+		LoadExtension(raiseExtension)
 }
 
 // NewBasicRuntime : NewCoreRuntime + minimal set of arithmetic extensions for Turing completeness
-func NewBasicRuntime() *Runtime {
-	return NewCoreRuntime().
+func NewBasicRuntime(opts ...Option) *Runtime {
+	return newBasicRuntime().apply(opts...)
+}
+
+func newBasicRuntime() *Runtime {
+	return newCoreRuntime().
 		LoadExtension(tailExtension).
 		LoadExtension(addExtension).
 		LoadExtension(subExtension).
@@ -49,22 +75,60 @@ func NewBasicRuntime() *Runtime {
 }
 
 // NewStdRuntime : NewCoreRuntime + standard functions
-func NewStdRuntime() *Runtime {
-	return NewBasicRuntime().
+func NewStdRuntime(opts ...Option) *Runtime {
+	return newStdRuntime().apply(opts...)
+}
+
+func newStdRuntime() *Runtime {
+	return newBasicRuntime().
 		LoadExtension(mulExtension).
 		LoadExtension(divExtension).
 		LoadExtension(modExtension).
 		LoadExtension(printExtension).
+		LoadExtension(printlnExtension).
+		LoadExtension(eprintExtension).
+		LoadExtension(readLineExtension).
 		LoadExtension(listExtension).
 		LoadExtension(appendExtension).
 		LoadExtension(sliceExtension).
 		LoadExtension(peekExtension).
 		LoadExtension(lenExtension).
-		LoadModule(mapModule).
+		LoadExtension(mapExtension).
 		LoadExtension(typeExtension).
-		LoadModule(stackModule).
+		LoadExtension(pureExtension).
+		LoadExtension(purityExtension).
+		LoadExtension(memoExtension).
+		LoadExtension(memoStatsExtension).
+		LoadExtension(stackExtension).
 		LoadModule(kaboomModule).
 		LoadExtension(doomExtension).
 		LoadExtension(timeExtension).
-		LoadExtension(rangeExtension)
+		LoadExtension(randExtension).
+		LoadExtension(rangeExtension).
+		LoadExtension(rangeStreamExtension).
+		LoadExtension(iterateExtension).
+		LoadExtension(repeatExtension).
+		LoadExtension(cycleExtension).
+		LoadExtension(streamMapExtension).
+		LoadExtension(streamFilterExtension).
+		LoadExtension(takeExtension).
+		LoadExtension(takeWhileExtension).
+		LoadExtension(toListExtension).
+		LoadModule(delayModule).
+		LoadExtension(forceExtension).
+		LoadExtension(spawnExtension).
+		LoadExtension(awaitExtension).
+		LoadExtension(chanExtension).
+		LoadExtension(sendExtension).
+		LoadExtension(recvExtension).
+		LoadExtension(closeExtension).
+		LoadModule(selectModule).
+		LoadExtension(okExtension).
+		LoadExtension(errExtension).
+		LoadExtension(someExtension).
+		LoadExtension(noneExtension).
+		LoadExtension(unwrapExtension).
+		LoadExtension(unwrapOrExtension).
+		LoadExtension(mapOkExtension).
+		LoadExtension(tryCallExtension)
 }
@@ -0,0 +1,66 @@
+package fp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// checkArgs : number of arguments given to the builtin name, max is -1 without limit
+func checkArgs(name String, got int, min int, max int) error {
+	if got >= min && (max < 0 || got <= max) {
+		return nil
+	}
+	var want string
+	switch {
+	case max < 0:
+		want = fmt.Sprintf("at least %d", min)
+	case min == max:
+		want = strconv.Itoa(min)
+	case min+1 == max:
+		want = fmt.Sprintf("%d or %d", min, max)
+	default:
+		want = fmt.Sprintf("%d to %d", min, max)
+	}
+	unit := "arguments"
+	if max == 1 || max < 0 && min == 1 {
+		unit = "argument"
+	}
+	return fmt.Errorf("%s requires %s %s, got %d", name, want, unit, got)
+}
+
+// argAs : the i-th value given to the builtin name as T, counted from 0
+func argAs[T Object](name String, values []Object, i int) (T, error) {
+	v, ok := values[i].(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("%s: argument %d must be %s, got %s", name, i+1, getType(zero), getType(values[i]))
+	}
+	return v, nil
+}
+
+// bindable : name of expr if it can be bound, literals like 3 and "s", *, _ and keywords are not names
+func (r *Runtime) bindable(expr Expr) (String, bool) {
+	name, ok := expr.(NameExpr)
+	if !ok || strings.HasPrefix(string(name), ":") {
+		return "", false
+	}
+	if _, err := r.parseLiteral(String(name)); err == nil {
+		return "", false
+	}
+	return String(name), true
+}
+
+// nameError : the i-th argument given to the module name, counted from 0, is not a name
+func nameError(name String, i int, arg Expr) error {
+	return fmt.Errorf("%s: argument %d must be a name, got %s", name, i+1, arg)
+}
+
+// argName : the i-th argument of a module binding a name, like let and del
+func (r *Runtime) argName(expr LambdaExpr, i int) (String, error) {
+	name, ok := r.bindable(expr.Args[i])
+	if !ok {
+		return "", nameError(String(expr.Name), i, expr.Args[i])
+	}
+	return name, nil
+}
@@ -0,0 +1,36 @@
+package fp
+
+import "testing"
+
+func TestBindingNames(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string // error
+	}{
+		{`(let 3 4)`, "let: argument 1 must be a name, got 3"},
+		{`(let "s" 4)`, `let: argument 1 must be a name, got "s"`},
+		{`(let * 5)`, "let: argument 1 must be a name, got *"},
+		{`(let _ 5)`, "let: argument 1 must be a name, got _"},
+		{`(let :x 5)`, "let: argument 1 must be a name, got :x"},
+		{`(del 3)`, "del: argument 1 must be a name, got 3"},
+		{`(defmacro 3 x x)`, "defmacro: argument 1 must be a name, got 3"},
+		{`(lambda 1 2 (add 1 2))`, "lambda: argument 1 must be a name, got 1"},
+		{`(lambda x (_ 2) x)`, "lambda: argument 2 must be a name, got _"},
+		{`(lambda * 3 x)`, "lambda: * must be followed by a name"},
+		{`(with (3 4) 3)`, "with: binding must be of the form (name expr), got (3 4)"},
+		{`(letrec ("s" 4) 3)`, `letrec: binding must be of the form (name expr), got ("s" 4)`},
+		{`(list (let 3 4) (add 1 2))`, "let: argument 1 must be a name, got 3"},
+	}
+	for _, test := range tests {
+		for _, opts := range [][]Option{nil, {WithParallelEvaluation(4)}} {
+			_, err := run(t, test.source, opts...)
+			if err == nil {
+				t.Errorf("%s: no error, want %s", test.source, test.want)
+				continue
+			}
+			if got := err.Error(); got != test.want {
+				t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+			}
+		}
+	}
+}
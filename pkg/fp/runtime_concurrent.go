@@ -0,0 +1,353 @@
+package fp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Future : result of a function running on another goroutine, see spawn
+type Future struct {
+	f *future
+}
+
+type future struct {
+	done  chan struct{}
+	value Object
+	err   error
+}
+
+func (f Future) String() string {
+	select {
+	case <-f.f.done:
+		if f.f.err != nil {
+			return fmt.Sprintf("(future error %s)", f.f.err)
+		}
+		return fmt.Sprintf("(future %v)", f.f.value)
+	default:
+		return "(future ...)"
+	}
+}
+
+func (f Future) MustTypeObject() {}
+
+// Channel : buffered channel of objects shared by runtimes, see chan
+type Channel struct {
+	c *channel
+}
+
+type channel struct {
+	ch     chan Object
+	mtx    sync.Mutex
+	closed bool
+}
+
+func (c Channel) String() string {
+	return fmt.Sprintf("(chan %d/%d)", len(c.c.ch), cap(c.c.ch))
+}
+
+func (c Channel) MustTypeObject() {}
+
+// spawn : call f in a fork of r on a new goroutine, the call is cancelled with ctx
+func (r *Runtime) spawn(ctx context.Context, f Object, args ...Object) Future {
+	child := r.Fork()
+	fut := Future{f: &future{done: make(chan struct{})}}
+	go func() {
+		defer close(fut.f.done)
+		defer func() {
+			if p := recover(); p != nil {
+				fut.f.value, fut.f.err = nil, &RuntimeError{Err: fmt.Errorf("runtime error: panic: %v", p)}
+			}
+		}()
+		fut.f.value, fut.f.err = child.Apply(ctx, f, args...)
+	}()
+	return fut
+}
+
+var spawnExtension = Extension{
+	Name: "spawn",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("spawn", len(values), 1, -1); err != nil {
+			return nil, err
+		}
+		switch values[0].(type) {
+		case Lambda, Memo, Module:
+		default:
+			return nil, fmt.Errorf("first argument must be function")
+		}
+		ec := mustGetExtensionContext(ctx)
+		// the task outlives the call to spawn, but not the context of the caller
+		return ec.Runtime().spawn(setOptionsToContext(ctx, &stepOptions{}), values[0], values[1:]...), nil
+	},
+	Man: "module: (spawn f 1 2) - call (f 1 2) on a new goroutine with a copy of the stack, get the result with await",
+}
+
+var awaitExtension = Extension{
+	Name: "await",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("await", len(values), 1, 2); err != nil {
+			return nil, err
+		}
+		fut, ok := values[0].(Future)
+		if !ok {
+			return nil, fmt.Errorf("first argument must be future")
+		}
+		var timeout <-chan time.Time
+		if len(values) == 2 {
+			ms, ok := values[1].(Int)
+			if !ok {
+				return nil, fmt.Errorf("second argument must be integer")
+			}
+			timer := time.NewTimer(time.Duration(ms) * time.Millisecond)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-fut.f.done:
+			return fut.f.value, fut.f.err
+		case <-timeout:
+			return nil, TimeoutError
+		case <-ctx.Done():
+			return nil, contextError(ctx)
+		}
+	},
+	Man: "module: (await f 1000) - wait for the result of a future, the timeout in milliseconds is optional",
+}
+
+var chanExtension = Extension{
+	Name: "chan",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("chan", len(values), 0, 1); err != nil {
+			return nil, err
+		}
+		size := Int(0)
+		if len(values) == 1 {
+			var ok bool
+			if size, ok = values[0].(Int); !ok || size < 0 {
+				return nil, fmt.Errorf("first argument must be non-negative integer")
+			}
+		}
+		return Channel{c: &channel{ch: make(chan Object, size)}}, nil
+	},
+	Man: "module: (chan 10) - make a channel with a buffer of 10 objects, unbuffered without size",
+}
+
+// send : send o on c, fails if c is closed
+func (c Channel) send(ctx context.Context, o Object) (err error) {
+	// closing while waiting makes the send panic
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("send on closed channel")
+		}
+	}()
+	if c.isClosed() {
+		return fmt.Errorf("send on closed channel")
+	}
+	select {
+	case c.c.ch <- o:
+		return nil
+	case <-ctx.Done():
+		return contextError(ctx)
+	}
+}
+
+func (c Channel) isClosed() bool {
+	c.c.mtx.Lock()
+	defer c.c.mtx.Unlock()
+	return c.c.closed
+}
+
+var sendExtension = Extension{
+	Name: "send",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("send", len(values), 2, 2); err != nil {
+			return nil, err
+		}
+		c, ok := values[0].(Channel)
+		if !ok {
+			return nil, fmt.Errorf("first argument must be channel")
+		}
+		if err := c.send(ctx, values[1]); err != nil {
+			return nil, err
+		}
+		return values[1], nil
+	},
+	Man: "module: (send c 3) - send a value on a channel, wait if the buffer is full",
+}
+
+// received : (some x), or (none) if the channel is closed
+func received(o Object, ok bool) Object {
+	if !ok {
+		return Optional{Some: false}
+	}
+	return Optional{Some: true, Value: o}
+}
+
+var recvExtension = Extension{
+	Name: "recv",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("recv", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		c, ok := values[0].(Channel)
+		if !ok {
+			return nil, fmt.Errorf("first argument must be channel")
+		}
+		select {
+		case o, ok := <-c.c.ch:
+			return received(o, ok), nil
+		case <-ctx.Done():
+			return nil, contextError(ctx)
+		}
+	},
+	Man: "module: (recv c) - wait for a value, (some x) or (none) when the channel is closed",
+}
+
+var closeExtension = Extension{
+	Name: "close",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("close", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		c, ok := values[0].(Channel)
+		if !ok {
+			return nil, fmt.Errorf("first argument must be channel")
+		}
+		c.c.mtx.Lock()
+		defer c.c.mtx.Unlock()
+		if c.c.closed {
+			return nil, fmt.Errorf("close of closed channel")
+		}
+		c.c.closed = true
+		close(c.c.ch)
+		return c, nil
+	},
+	Man: "module: (close c) - close a channel, waiting recv get (none)",
+}
+
+// selectCase : clause of select, the body is evaluated when the case is chosen
+type selectCase struct {
+	name NameExpr // name bound to the received value for recv
+	body []Expr
+	dir  reflect.SelectDir
+	sent Object
+}
+
+var selectModule = Module{
+	Name: "select",
+	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
+		if err := checkArgs("select", len(expr.Args), 1, -1); err != nil {
+			// without clause, select would wait forever
+			return nil, err
+		}
+		body := ctx
+		// channels and values are never tail calls
+		ctx = setOptionsToContext(ctx, &stepOptions{})
+		var clauses []selectCase
+		var cases []reflect.SelectCase
+		for _, arg := range expr.Args {
+			c, ok := arg.(LambdaExpr)
+			if !ok {
+				return nil, fmt.Errorf("select: clause must be (recv c x ...), (send c v ...), (timeout ms ...) or (default ...), got %s", arg)
+			}
+			switch c.Name {
+			case "recv", "send":
+				if len(c.Args) < 2 {
+					return nil, fmt.Errorf("select: %s requires at least 2 arguments", c.Name)
+				}
+				o, err := r.Step(ctx, c.Args[0])
+				if err != nil {
+					return nil, err
+				}
+				ch, ok := o.(Channel)
+				if !ok {
+					return nil, fmt.Errorf("select: first argument of %s must be channel", c.Name)
+				}
+				sc := selectCase{body: c.Args[2:]}
+				if c.Name == "recv" {
+					if sc.name, ok = c.Args[1].(NameExpr); !ok {
+						return nil, fmt.Errorf("select: second argument of recv must be a name")
+					}
+					sc.dir = reflect.SelectRecv
+					cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.c.ch)})
+				} else {
+					if ch.isClosed() {
+						return nil, fmt.Errorf("send on closed channel")
+					}
+					if sc.sent, err = r.Step(ctx, c.Args[1]); err != nil {
+						return nil, err
+					}
+					sc.dir = reflect.SelectSend
+					cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch.c.ch), Send: reflect.ValueOf(&sc.sent).Elem()})
+				}
+				clauses = append(clauses, sc)
+			case "timeout":
+				if len(c.Args) < 1 {
+					return nil, fmt.Errorf("select: timeout requires a duration in milliseconds")
+				}
+				o, err := r.Step(ctx, c.Args[0])
+				if err != nil {
+					return nil, err
+				}
+				ms, ok := o.(Int)
+				if !ok {
+					return nil, fmt.Errorf("select: timeout must be integer")
+				}
+				timer := time.NewTimer(time.Duration(ms) * time.Millisecond)
+				defer timer.Stop()
+				clauses = append(clauses, selectCase{body: c.Args[1:], dir: reflect.SelectRecv})
+				cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)})
+			case "default":
+				clauses = append(clauses, selectCase{body: c.Args, dir: reflect.SelectDefault})
+				cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+			default:
+				return nil, fmt.Errorf("select: unknown clause %s", c.Name)
+			}
+		}
+		// the last case is always the cancellation of ctx
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+		i, v, ok, err := func() (i int, v reflect.Value, ok bool, err error) {
+			defer func() {
+				if recover() != nil {
+					err = fmt.Errorf("send on closed channel")
+				}
+			}()
+			i, v, ok = reflect.Select(cases)
+			return i, v, ok, nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+		if i == len(clauses) {
+			return nil, contextError(ctx)
+		}
+		sc := clauses[i]
+		var result Object = Optional{Some: false}
+		frame := make(Frame).Update(r.Stack[len(r.Stack)-1])
+		switch {
+		case sc.name != "":
+			var o Object
+			if ok {
+				o, _ = v.Interface().(Object)
+			}
+			result = received(o, ok)
+			frame[String(sc.name)] = result
+		case sc.dir == reflect.SelectSend:
+			result = sc.sent
+		}
+		if len(sc.body) == 0 {
+			return result, nil
+		}
+		depth := len(r.Stack)
+		r.Stack = append(r.Stack, frame)
+		defer r.unwind(depth)
+		outputs, err := r.stepMany(body, sc.body...)
+		if err != nil {
+			return nil, err
+		}
+		return outputs[len(outputs)-1], nil
+	},
+	Man: "module: (select (recv c x (print x)) (send d 1) (timeout 100 (print \"too slow\"))) - wait for the first ready channel, x is (some v) or (none)",
+}
@@ -1,16 +1,28 @@
 package fp
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"sync"
 	"time"
 )
 
 type Runtime struct {
 	parseLiteral func(lit String) (Object, error)
-	Stack        []Frame `json:"stack,omitempty"`
+	Stack        []Frame   `json:"stack,omitempty"`
+	Options      Options   `json:"-"`
+	Stdout       io.Writer `json:"-"`
+	Stderr       io.Writer `json:"-"`
+	Stdin        io.Reader `json:"-"`
+	stdin        *bufio.Reader
+	stdinSource  io.Reader
+	values       *sync.Map     // see ExtensionContext.Load, ExtensionContext.Store
+	shared       int           // frames below this index may be shared with forks, see Fork
+	workers      chan struct{} // one token per goroutine evaluating an argument, see WithParallelEvaluation
+	forkMtx      sync.Mutex
 }
 type Frame map[String]Object
 
@@ -21,25 +33,22 @@ func (f Frame) Update(otherFrame Frame) Frame {
 	return f
 }
 
+// stdinReader : buffered reader of Stdin, kept across calls so no input is lost
+func (r *Runtime) stdinReader() *bufio.Reader {
+	if r.stdin == nil || r.stdinSource != r.Stdin {
+		r.stdin, r.stdinSource = bufio.NewReader(r.Stdin), r.Stdin
+	}
+	return r.stdin
+}
+
 func (r *Runtime) LoadModule(m Module) *Runtime {
-	r.Stack[0][m.Name] = m
+	r.writableFrame(0)[m.Name] = m
 	return r
 }
 
-const (
-	SIMPLE_DETECT_NONPURE = false
-	MAX_STACK_DEPTH       = 1000
-	TAILCALL_OPTIMIZATION = true
-)
-
 func (r *Runtime) searchOnStack(name String) (Object, error) {
 	for i := len(r.Stack) - 1; i >= 0; i-- {
 		if o, ok := r.Stack[i][name]; ok {
-			if SIMPLE_DETECT_NONPURE {
-				if i != 0 && i < len(r.Stack)-1 {
-					_, _ = fmt.Fprintf(os.Stderr, "non-pure function")
-				}
-			}
 			return o, nil
 		}
 	}
@@ -54,8 +63,11 @@ type stepOptions struct {
 	tailCall bool
 }
 
+// stepOptionsKey : private context key, other packages cannot collide with it
+type stepOptionsKey struct{}
+
 func getOptionsFromContext(ctx context.Context) (*stepOptions, bool) {
-	if o, ok := ctx.Value("step_options").(*stepOptions); ok {
+	if o, ok := ctx.Value(stepOptionsKey{}).(*stepOptions); ok {
 		return o, true
 	}
 	// default option
@@ -64,14 +76,94 @@ func getOptionsFromContext(ctx context.Context) (*stepOptions, bool) {
 	}, false
 }
 
+// setOptionsToContext : ctx itself if it already has the options o, so that deep recursions do not grow the chain of contexts
 func setOptionsToContext(ctx context.Context, o *stepOptions) context.Context {
-	return context.WithValue(ctx, "step_options", o)
+	if current, _ := getOptionsFromContext(ctx); *current == *o {
+		return ctx
+	}
+	return context.WithValue(ctx, stepOptionsKey{}, o)
+}
+
+// RuntimeError : error returned by Step, with the names of the calls it went through
+type RuntimeError struct {
+	Err   error
+	Trace []String // innermost call first
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// maxTraceLength : only the innermost calls are kept in RuntimeError.Trace
+const maxTraceLength = 64
+
+// withTrace : add a call to the trace of err, err becomes a RuntimeError if it is not one yet
+func withTrace(err error, name String) error {
+	var re *RuntimeError
+	if !errors.As(err, &re) {
+		re = &RuntimeError{Err: err}
+		err = re
+	}
+	if len(re.Trace) < maxTraceLength {
+		re.Trace = append(re.Trace, name)
+	}
+	return err
+}
+
+// Step - evaluate an expression
+//
+// on any error, including a cancelled ctx or a panic in a module, the stack is unwound to its size before the call
+// so that the runtime can keep being used
+func (r *Runtime) Step(ctx context.Context, expr Expr) (o Object, err error) {
+	depth := len(r.Stack)
+	if d := r.Options.Debugger; d != nil {
+		if err := d.Enter(ctx, r, expr); err != nil {
+			return nil, err
+		}
+		// deferred first so that it sees the error of a recovered panic
+		defer func() {
+			d.Leave(ctx, r, expr, o, err)
+		}()
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			o, err = nil, &RuntimeError{Err: fmt.Errorf("runtime error: panic: %v", p)}
+		}
+		if err != nil {
+			r.unwind(depth)
+			if expr, ok := expr.(LambdaExpr); ok {
+				err = withTrace(err, String(expr.Name))
+			}
+		}
+	}()
+	return r.step(ctx, expr)
+}
+
+// unwind : pop frames above depth
+func (r *Runtime) unwind(depth int) {
+	if len(r.Stack) > depth {
+		r.Stack = r.Stack[:depth]
+	}
+}
+
+// contextError : TimeoutError or InterruptError if ctx is done
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return TimeoutError
+		}
+		return InterruptError
+	}
+	return nil
 }
 
-// Step -
-func (r *Runtime) Step(ctx context.Context, expr Expr) (Object, error) {
-	if ctx.Err() != nil {
-		return nil, ctx.Err()
+func (r *Runtime) step(ctx context.Context, expr Expr) (Object, error) {
+	if err := contextError(ctx); err != nil {
+		return nil, err
 	}
 
 	options, _ := getOptionsFromContext(ctx)
@@ -80,7 +172,7 @@ func (r *Runtime) Step(ctx context.Context, expr Expr) (Object, error) {
 	if ok && time.Now().After(deadline) {
 		return nil, TimeoutError
 	}
-	if len(r.Stack) > MAX_STACK_DEPTH {
+	if len(r.Stack) > r.Options.MaxStackDepth {
 		return nil, StackOverflowError
 	}
 	select {
@@ -106,33 +198,28 @@ func (r *Runtime) Step(ctx context.Context, expr Expr) (Object, error) {
 			switch f := f.(type) {
 			case Lambda:
 				// 1. evaluate arguments
-				args, err := r.stepMany(ctx, expr.Args...)
+				args, kwargs, err := r.stepCallArgs(ctx, func() bool {
+					return r.lambdaPurity(String(expr.Name), f) != Effectful
+				}, expr.Args...)
 				if err != nil {
 					return nil, err
 				}
-				// 2. add argument to local Frame
-				localFrame := make(Frame).Update(f.Frame)
-				for i := 0; i < len(f.Params); i++ {
-					localFrame[f.Params[i]] = args[i]
-				}
-				// 3. push Frame to Stack
-				if options.tailCall {
-					r.Stack[len(r.Stack)-1].Update(localFrame)
-				} else {
-					r.Stack = append(r.Stack, localFrame)
-				}
-				// 4. exec function
-				v, err := r.Step(ctx, f.Impl)
+				return r.callLambda(ctx, String(expr.Name), f, args, kwargs, options.tailCall)
+			case Memo:
+				args, kwargs, err := r.stepCallArgs(ctx, nil, expr.Args...)
 				if err != nil {
 					return nil, err
 				}
-				// 5. pop Frame from Stack
-				if !options.tailCall {
-					r.Stack = r.Stack[:len(r.Stack)-1]
-				}
-				return v, nil
+				return f.call(ctx, r, String(expr.Name), args, kwargs, options.tailCall)
 			case Module:
 				return f.Exec(ctx, r, expr)
+			case Macro:
+				// macros not expanded by Expand, e.g. defined in the same expression
+				expanded, err := r.expandMacro(ctx, f, expr)
+				if err != nil {
+					return nil, err
+				}
+				return r.Step(ctx, expanded)
 			default:
 				return nil, fmt.Errorf("function or module %s found but wrong type", expr.Name.String())
 			}
@@ -142,26 +229,108 @@ func (r *Runtime) Step(ctx context.Context, expr Expr) (Object, error) {
 	}
 }
 
-func (r *Runtime) stepMany(ctx context.Context, exprList ...Expr) ([]Object, error) {
-	var outputs []Object
-	if len(exprList) != 0 {
-		for i, expr := range exprList {
-			if TAILCALL_OPTIMIZATION {
-				if i == len(exprList)-1 && len(exprList) >= 2 { // TODO somehow if exprList is of length 1 then error
-					options, found := getOptionsFromContext(ctx)
-					options.tailCall = true
-					if !found {
-						ctx = setOptionsToContext(ctx, options)
-					}
-				}
+func (r *Runtime) callLambda(ctx context.Context, name String, f Lambda, args []Object, kwargs map[String]Object, tailCall bool) (Object, error) {
+	// 2. add argument to local Frame
+	localFrame, err := r.bindParams(ctx, name, f, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	// 3. push Frame to Stack
+	depth := len(r.Stack)
+	// bindings of the last frame replaced by a tail call, restored on error
+	var overwritten Frame
+	var added []String
+	if tailCall {
+		lastFrame := r.writableFrame(depth - 1)
+		overwritten = make(Frame)
+		for name := range localFrame {
+			if o, ok := lastFrame[name]; ok {
+				overwritten[name] = o
+			} else {
+				added = append(added, name)
 			}
-			v, err := r.Step(ctx, expr)
-			if err != nil {
-				return nil, err
+		}
+		lastFrame.Update(localFrame)
+	} else {
+		r.Stack = append(r.Stack, localFrame)
+	}
+	// 4. exec function
+	if d := r.Options.Debugger; d != nil {
+		err = d.Call(ctx, r, name, f)
+	}
+	var v Object
+	if err == nil {
+		// the body is the last expression evaluated in the frame of the call, calls in tail position replace it
+		v, err = r.Step(setOptionsToContext(ctx, &stepOptions{tailCall: r.Options.TailCallOptimization}), f.Impl)
+	}
+	if err != nil {
+		r.unwind(depth)
+		if tailCall && len(r.Stack) == depth {
+			lastFrame := r.writableFrame(depth - 1).Update(overwritten)
+			for _, name := range added {
+				delete(lastFrame, name)
 			}
-			outputs = append(outputs, v)
 		}
+		return nil, err
+	}
+	// 5. pop Frame from Stack, a tail call did not push one
+	r.unwind(depth)
+	return v, nil
+}
+
+// Apply : call a Lambda, a Memo or a Module with already evaluated arguments
+func (r *Runtime) Apply(ctx context.Context, f Object, args ...Object) (Object, error) {
+	switch f := f.(type) {
+	case Lambda:
+		return r.callLambda(ctx, "lambda", f, args, nil, false)
+	case Memo:
+		return f.call(ctx, r, "memo", args, nil, false)
+	case Module:
+		// modules take expressions, bind arguments to names in a temporary frame
+		argFrame := make(Frame)
+		argExprs := make([]Expr, 0, len(args))
+		for i, arg := range args {
+			name := String(fmt.Sprintf("$%d", i))
+			argFrame[name] = arg
+			argExprs = append(argExprs, NameExpr(name))
+		}
+		depth := len(r.Stack)
+		r.Stack = append(r.Stack, argFrame)
+		defer r.unwind(depth)
+		return f.Exec(ctx, r, LambdaExpr{
+			Name: NameExpr(f.Name),
+			Args: argExprs,
+		})
+	default:
+		return nil, fmt.Errorf("runtime error: cannot apply %s", getType(f))
+	}
+}
 
+// applyName : name of a call of f by Apply, for traces
+func applyName(f Object) String {
+	switch f := f.(type) {
+	case Memo:
+		return "memo"
+	case Module:
+		return f.Name
+	default:
+		return "lambda"
+	}
+}
+
+func (r *Runtime) stepMany(ctx context.Context, exprList ...Expr) ([]Object, error) {
+	var outputs []Object
+	for i, expr := range exprList {
+		stepCtx := ctx
+		if options, _ := getOptionsFromContext(ctx); options.tailCall && i < len(exprList)-1 {
+			// only the last expression can be a tail call, the frame is still used after the others
+			stepCtx = setOptionsToContext(ctx, &stepOptions{})
+		}
+		v, err := r.Step(stepCtx, expr)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, v)
 	}
 	return outputs, nil
 }
@@ -0,0 +1,17 @@
+package fp
+
+import (
+	"context"
+)
+
+// Debugger : hooks called by Step on the goroutine of the runtime, see WithDebugger
+//
+// blocking in a hook pauses the evaluation, the stack of the runtime can be read until the hook returns
+type Debugger interface {
+	// Enter : before expr is evaluated, an error stops the evaluation
+	Enter(ctx context.Context, r *Runtime, expr Expr) error
+	// Call : the frame of a call to lambda f is on top of the stack, before its body is evaluated
+	Call(ctx context.Context, r *Runtime, name String, f Lambda) error
+	// Leave : after expr is evaluated, for every Enter that returned nil
+	Leave(ctx context.Context, r *Runtime, expr Expr, o Object, err error)
+}
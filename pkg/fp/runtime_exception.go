@@ -0,0 +1,146 @@
+package fp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RaisedError : error raised by (raise x), x is the payload given to catch
+type RaisedError struct {
+	Payload Object
+}
+
+func (e *RaisedError) Error() string {
+	return fmt.Sprintf("raised: %v", e.Payload)
+}
+
+// errorType : value of the "type" key of a caught builtin error
+func errorTypeOf(err error) String {
+	switch {
+	case errors.Is(err, StackOverflowError):
+		return "StackOverflowError"
+	case errors.Is(err, TimeoutError):
+		return "TimeoutError"
+	case errors.Is(err, InterruptError):
+		return "InterruptError"
+	default:
+		return "RuntimeError"
+	}
+}
+
+// errorToObject : payload of a raised error, or a Dict with type, message and trace for builtin errors
+func errorToObject(err error) Object {
+	var raised *RaisedError
+	if errors.As(err, &raised) {
+		return raised.Payload
+	}
+	trace := List{}
+	var re *RuntimeError
+	if errors.As(err, &re) {
+		for _, name := range re.Trace {
+			trace = append(trace, name)
+		}
+	}
+	return Dict{
+		String("type"):    errorTypeOf(err),
+		String("message"): String(err.Error()),
+		String("trace"):   trace,
+	}
+}
+
+// finallyTimeout : time given to finally once the program is interrupted or timed out
+const finallyTimeout = time.Second
+
+// catchable : interrupts and timeouts always stop the program, finally is still executed for at most finallyTimeout
+func catchable(err error) bool {
+	return !errors.Is(err, InterruptError) && !errors.Is(err, TimeoutError)
+}
+
+var raiseExtension = Extension{
+	Name: "raise",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("raise", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		return nil, &RaisedError{Payload: values[0]}
+	},
+	Man: "module: (raise (list \"not found\" x)) - raise any object as an error, see try",
+}
+
+// clause : (catch e expr...) or (finally expr...) inside try
+func clause(expr Expr, name NameExpr) (LambdaExpr, bool) {
+	e, ok := expr.(LambdaExpr)
+	return e, ok && e.Name == name
+}
+
+var tryModule = Module{
+	Name: "try",
+	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (o Object, err error) {
+		var body []Expr
+		var catchExpr, finallyExpr *LambdaExpr
+		for _, arg := range expr.Args {
+			if c, ok := clause(arg, "catch"); ok {
+				if catchExpr != nil || finallyExpr != nil {
+					return nil, fmt.Errorf("try: catch must appear once, before finally")
+				}
+				if len(c.Args) < 2 {
+					return nil, fmt.Errorf("try: catch requires a name and at least 1 expression")
+				}
+				if _, ok := c.Args[0].(NameExpr); !ok {
+					return nil, fmt.Errorf("try: first argument of catch must be a name")
+				}
+				catchExpr = &c
+			} else if f, ok := clause(arg, "finally"); ok {
+				if finallyExpr != nil {
+					return nil, fmt.Errorf("try: finally must appear once")
+				}
+				finallyExpr = &f
+			} else {
+				if catchExpr != nil || finallyExpr != nil {
+					return nil, fmt.Errorf("try: catch and finally must be the last arguments")
+				}
+				body = append(body, arg)
+			}
+		}
+		if len(body) == 0 {
+			return nil, fmt.Errorf("try requires at least 1 expression")
+		}
+		if finallyExpr != nil {
+			defer func() {
+				finallyCtx := ctx
+				if err != nil && !catchable(err) {
+					// the program is stopping, finally still gets some time to release what the body acquired
+					var cancel context.CancelFunc
+					finallyCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), finallyTimeout)
+					defer cancel()
+				}
+				// an error in finally replaces the result, but not the interrupt or the timeout of the program
+				if _, finallyErr := r.stepMany(finallyCtx, finallyExpr.Args...); finallyErr != nil && (err == nil || catchable(err)) {
+					o, err = nil, finallyErr
+				}
+			}()
+		}
+		// catch and finally run in the frame of try, a tail call of the body must not replace it
+		outputs, err := r.stepMany(setOptionsToContext(ctx, &stepOptions{}), body...)
+		if err == nil {
+			return outputs[len(outputs)-1], nil
+		}
+		if catchExpr == nil || !catchable(err) {
+			return nil, err
+		}
+		// bind the error in a new frame for the handler
+		depth := len(r.Stack)
+		r.Stack = append(r.Stack, Frame{
+			String(catchExpr.Args[0].(NameExpr)): errorToObject(err),
+		})
+		defer r.unwind(depth)
+		outputs, err = r.stepMany(ctx, catchExpr.Args[1:]...)
+		if err != nil {
+			return nil, err
+		}
+		return outputs[len(outputs)-1], nil
+	},
+	Man: "module: (try (div 1 0) (catch e (peek e \"message\")) (finally (print \"done\"))) - handle errors, e is the raised object or a dict with type, message and trace",
+}
@@ -0,0 +1,93 @@
+package fp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTryFinally(t *testing.T) {
+	const defs = `(let f (lambda x x)) (let loop (lambda n (loop n))) `
+	tests := []struct {
+		source string
+		want   string
+		output string
+	}{
+		{`(let h (lambda x (try (print x) (f 100) (finally (print "finally-x" x))))) (h 5)`, "100", "5 \nfinally-x 5 \n"},
+		{`(let h (lambda x (try (list 1 (f 100)) (finally (print "finally-x" x))))) (h 5)`, "[1,100,]", "finally-x 5 \n"},
+		{`(let h (lambda x (try (raise 1) (catch e (f 100)) (finally (print "finally-x" x))))) (h 5)`, "100", "finally-x 5 \n"},
+		{`(let h (lambda x (try (print 1) (f 100)))) (h 5)`, "100", "1 \n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		o, err := run(t, defs+test.source, WithStdout(&out))
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+		if out.String() != test.output {
+			t.Errorf("%s: printed %q, want %q", test.source, out.String(), test.output)
+		}
+	}
+}
+
+func TestTryFinallyTimeout(t *testing.T) {
+	exprs, err := ParseSource(`(try (loop 1) (finally (print "cleanup")))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	r := NewStdRuntime(WithStdout(&out))
+	loop, _ := ParseSource(`(let loop (lambda n (loop n)))`)
+	if _, err := r.Step(context.Background(), loop[0]); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = r.Step(ctx, exprs[0])
+	if !errors.Is(err, TimeoutError) {
+		t.Errorf("got error %v, want %v", err, TimeoutError)
+	}
+	if out.String() != "cleanup \n" {
+		t.Errorf("printed %q, want the output of finally (%v)", out.String(), err)
+	}
+}
+
+func TestTryCallTrace(t *testing.T) {
+	const defs = `(let f (lambda x (div x 0))) `
+	tests := []struct {
+		source string
+		trace  string
+	}{
+		{`(try (div 1 0) (catch e e))`, "[div,]"},
+		{`(try-call div 1 0)`, "[div,]"},
+		{`(try (f 1) (catch e e))`, "[div,f,]"},
+		{`(try-call f 1)`, "[div,lambda,]"},
+	}
+	for _, test := range tests {
+		o, err := run(t, defs+test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if r, ok := o.(Result); ok {
+			o = r.Value
+		}
+		d, ok := o.(Dict)
+		if !ok {
+			t.Errorf("%s: got %s, want a dict", test.source, o)
+			continue
+		}
+		if got := d[String("trace")].String(); got != test.trace {
+			t.Errorf("%s: trace %s, want %s", test.source, got, test.trace)
+		}
+		if got := d[String("type")]; got != String("RuntimeError") {
+			t.Errorf("%s: type %s, want RuntimeError", test.source, got)
+		}
+	}
+}
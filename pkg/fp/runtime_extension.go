@@ -0,0 +1,93 @@
+package fp
+
+import (
+	"context"
+	"io"
+)
+
+// ExtensionContext : the ctx given to Extension.Exec is always an ExtensionContext,
+// use GetExtensionContext to get it back from a derived context
+type ExtensionContext interface {
+	context.Context
+	Runtime() *Runtime
+	// Apply : call a Lambda or a Module with already evaluated arguments
+	Apply(f Object, args ...Object) (Object, error)
+	Stdout() io.Writer
+	Stderr() io.Writer
+	Stdin() io.Reader
+	// Expr : the expression calling the extension
+	Expr() LambdaExpr
+	// Load, Store : key/value store shared by every extension of the runtime
+	Load(key string) (any, bool)
+	Store(key string, value any)
+}
+
+type extensionContextKey struct{}
+
+type extensionContext struct {
+	context.Context
+	r    *Runtime
+	expr LambdaExpr
+}
+
+func newExtensionContext(ctx context.Context, r *Runtime, expr LambdaExpr) ExtensionContext {
+	return &extensionContext{
+		Context: ctx,
+		r:       r,
+		expr:    expr,
+	}
+}
+
+// GetExtensionContext : get the ExtensionContext from the ctx of Extension.Exec or any context derived from it
+func GetExtensionContext(ctx context.Context) (ExtensionContext, bool) {
+	ec, ok := ctx.Value(extensionContextKey{}).(ExtensionContext)
+	return ec, ok
+}
+
+func (ec *extensionContext) Value(key any) any {
+	if _, ok := key.(extensionContextKey); ok {
+		return ec
+	}
+	return ec.Context.Value(key)
+}
+
+func (ec *extensionContext) Runtime() *Runtime {
+	return ec.r
+}
+
+func (ec *extensionContext) Apply(f Object, args ...Object) (Object, error) {
+	return ec.r.Apply(ec.Context, f, args...)
+}
+
+func (ec *extensionContext) Stdout() io.Writer {
+	return ec.r.Stdout
+}
+
+func (ec *extensionContext) Stderr() io.Writer {
+	return ec.r.Stderr
+}
+
+func (ec *extensionContext) Stdin() io.Reader {
+	return ec.r.stdinReader()
+}
+
+func (ec *extensionContext) Expr() LambdaExpr {
+	return ec.expr
+}
+
+func (ec *extensionContext) Load(key string) (any, bool) {
+	return ec.r.values.Load(key)
+}
+
+func (ec *extensionContext) Store(key string, value any) {
+	ec.r.values.Store(key, value)
+}
+
+// mustGetExtensionContext : for builtin extensions, which are always called through makeModuleFromExtension
+func mustGetExtensionContext(ctx context.Context) ExtensionContext {
+	ec, ok := GetExtensionContext(ctx)
+	if !ok {
+		panic("extension called without extension context")
+	}
+	return ec
+}
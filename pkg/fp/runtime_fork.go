@@ -0,0 +1,46 @@
+package fp
+
+import (
+	"math/rand"
+)
+
+// Fork : copy-on-write child runtime
+//
+// the child shares every frame of the stack, including the global frame and builtins, with r.
+// a frame is copied by the first runtime writing into it (let, del, tail call, LoadModule),
+// so bindings made in the child never leak into r or other forks and vice versa.
+// Fork can be called from many goroutines at the same time, but not while r is running Step
+func (r *Runtime) Fork() *Runtime {
+	r.forkMtx.Lock()
+	defer r.forkMtx.Unlock()
+	if r.shared < len(r.Stack) {
+		r.shared = len(r.Stack)
+	}
+	options := r.Options
+	options.Rand = rand.New(rand.NewSource(r.Options.Rand.Int63()))
+	// forks run on other goroutines, they never pause
+	options.Debugger = nil
+	return &Runtime{
+		parseLiteral: r.parseLiteral,
+		Stack:        append([]Frame(nil), r.Stack...),
+		Options:      options,
+		Stdout:       r.Stdout,
+		Stderr:       r.Stderr,
+		Stdin:        r.Stdin,
+		values:       r.values,
+		shared:       len(r.Stack),
+		workers:      r.workers,
+	}
+}
+
+// writableFrame : frame i of the stack, copied first if it may be shared with a fork
+func (r *Runtime) writableFrame(i int) Frame {
+	if i < r.shared {
+		r.Stack[i] = make(Frame).Update(r.Stack[i])
+		if i == len(r.Stack)-1 {
+			// frames above i were popped, every frame from i is now owned by r
+			r.shared = i
+		}
+	}
+	return r.Stack[i]
+}
@@ -0,0 +1,85 @@
+package fp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestForkIsolation(t *testing.T) {
+	tests := []struct {
+		parent string // evaluated on the parent after the fork
+		child  string // evaluated on the child
+		check  string // evaluated on both after parent and child
+		want   string // on the parent
+		forked string // on the child
+	}{
+		{parent: `0`, child: `(let x 2)`, check: `x`, want: "1", forked: "2"},
+		{parent: `(let x 3)`, child: `0`, check: `x`, want: "3", forked: "1"},
+		{parent: `0`, child: `(del x)`, check: `(add x 0)`, want: "1", forked: "error"},
+		{parent: `(let y 1)`, child: `(let y 2)`, check: `y`, want: "1", forked: "2"},
+		// a global binding made during a call is copied into the frame of the call
+		{parent: `0`, child: `(let f (lambda (let x 5))) (f) x`, check: `x`, want: "1", forked: "1"},
+		{parent: `0`, child: `(let inc (lambda n (add n 100)))`, check: `(inc x)`, want: "2", forked: "101"},
+	}
+	for _, test := range tests {
+		r := NewStdRuntime()
+		eval(t, r, `(let x 1) (let inc (lambda n (add n 1)))`)
+		child := r.Fork()
+		eval(t, r, test.parent)
+		eval(t, child, test.child)
+		if got := checkString(r, test.check); got != test.want {
+			t.Errorf("%s / %s: %s on the parent is %s, want %s", test.parent, test.child, test.check, got, test.want)
+		}
+		if got := checkString(child, test.check); got != test.forked {
+			t.Errorf("%s / %s: %s on the child is %s, want %s", test.parent, test.child, test.check, got, test.forked)
+		}
+	}
+}
+
+// checkString : value of source on r, "error" if it fails
+func checkString(r *Runtime, source string) string {
+	exprs, err := ParseSource(source)
+	if err != nil {
+		return err.Error()
+	}
+	o, err := r.Step(context.Background(), exprs[0])
+	if err != nil {
+		return "error"
+	}
+	return o.String()
+}
+
+func TestForkConcurrent(t *testing.T) {
+	r := NewStdRuntime()
+	eval(t, r, `(let x 0) (let f (lambda n (add n x)))`)
+	children := make([]*Runtime, 8)
+	for i := range children {
+		children[i] = r.Fork()
+	}
+	var wg sync.WaitGroup
+	for i, child := range children {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				checkString(child, fmt.Sprintf(`(let x %d)`, i))
+				checkString(child, fmt.Sprintf(`(let y (f %d))`, j))
+			}
+		}()
+	}
+	wg.Wait()
+	for i, child := range children {
+		// f reads the x it captured
+		if got, want := checkString(child, `(list x y)`), fmt.Sprintf("[%d,9,]", i); got != want {
+			t.Errorf("child %d: got %s, want %s", i, got, want)
+		}
+	}
+	if got := checkString(r, `(list x (f 1))`); got != "[0,1,]" {
+		t.Errorf("parent: got %s, want [0,1,]", got)
+	}
+	if got := checkString(r, `(add y 0)`); got != "error" {
+		t.Errorf("parent: y is %s, want unbound", got)
+	}
+}
@@ -0,0 +1,178 @@
+package fp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// parseParams : parameters of (lambda x (y 2) * rest body)
+//
+// x is required, y is optional with default value 2, rest is the list of the remaining arguments
+func (r *Runtime) parseParams(params []Expr) (names []String, defaults map[String]Expr, rest String, err error) {
+	for i := 0; i < len(params); i++ {
+		if rest != "" {
+			return nil, nil, "", fmt.Errorf("lambda: no parameter allowed after * %s", rest)
+		}
+		switch p := params[i].(type) {
+		case NameExpr:
+			if p == "*" {
+				if i+1 >= len(params) {
+					return nil, nil, "", fmt.Errorf("lambda: * must be followed by a name")
+				}
+				name, ok := r.bindable(params[i+1])
+				if !ok {
+					return nil, nil, "", fmt.Errorf("lambda: * must be followed by a name")
+				}
+				rest = name
+				i++
+				continue
+			}
+			name, ok := r.bindable(p)
+			if !ok {
+				return nil, nil, "", nameError("lambda", i, p)
+			}
+			if len(defaults) > 0 {
+				return nil, nil, "", fmt.Errorf("lambda: required parameter %s after optional parameters", p)
+			}
+			names = append(names, name)
+		case LambdaExpr:
+			if len(p.Args) != 1 {
+				return nil, nil, "", fmt.Errorf("lambda: optional parameter must be of the form (name default), got %s", p)
+			}
+			name, ok := r.bindable(p.Name)
+			if !ok {
+				return nil, nil, "", nameError("lambda", i, p.Name)
+			}
+			if defaults == nil {
+				defaults = make(map[String]Expr)
+			}
+			names = append(names, name)
+			defaults[name] = p.Args[0]
+		}
+	}
+	return names, defaults, rest, nil
+}
+
+// isKeyword : :name at a call site, the next argument is the value of parameter name
+func isKeyword(expr Expr) (String, bool) {
+	e, ok := expr.(NameExpr)
+	if !ok || len(e) < 2 || !strings.HasPrefix(string(e), ":") {
+		return "", false
+	}
+	return String(e[1:]), true
+}
+
+// stepCallArgs : evaluate positional arguments then keyword arguments in order, like stepPureArgs
+//
+// they are evaluated together so that only the value evaluated last can be a tail call replacing the frame
+func (r *Runtime) stepCallArgs(ctx context.Context, pure func() bool, exprList ...Expr) ([]Object, map[String]Object, error) {
+	var positional []Expr
+	var names []String
+	var values []Expr
+	for i := 0; i < len(exprList); i++ {
+		name, ok := isKeyword(exprList[i])
+		if !ok {
+			positional = append(positional, exprList[i])
+			continue
+		}
+		if i+1 >= len(exprList) || exprList[i+1] == NameExpr("*") {
+			return nil, nil, fmt.Errorf("keyword argument :%s without value", name)
+		}
+		if indexOf(names, name) >= 0 {
+			return nil, nil, fmt.Errorf("keyword argument :%s given twice", name)
+		}
+		names = append(names, name)
+		values = append(values, exprList[i+1])
+		i++
+	}
+	args, err := r.stepPureArgs(ctx, pure, append(positional, values...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(names) == 0 {
+		return args, nil, nil
+	}
+	// (* list) only expands positional arguments, the keyword values are the last ones
+	args, kwValues := args[:len(args)-len(values)], args[len(args)-len(values):]
+	kwargs := make(map[String]Object, len(names))
+	for i, name := range names {
+		kwargs[name] = kwValues[i]
+	}
+	return args, kwargs, nil
+}
+
+// arityError : wrong number of positional arguments given to f
+func arityError(name String, f Lambda, got int) error {
+	max := len(f.Params)
+	if f.Rest != "" {
+		max = -1
+	}
+	return checkArgs(name, got, len(f.Params)-len(f.Defaults), max)
+}
+
+// bindParams : frame of a call to f, default values are evaluated in this frame so they can use the previous parameters
+func (r *Runtime) bindParams(ctx context.Context, name String, f Lambda, args []Object, kwargs map[String]Object) (Frame, error) {
+	if len(args) > len(f.Params) && f.Rest == "" {
+		return nil, arityError(name, f, len(args))
+	}
+	localFrame := make(Frame).Update(f.Frame)
+	for i := 0; i < len(f.Params) && i < len(args); i++ {
+		localFrame[f.Params[i]] = args[i]
+	}
+	if f.Rest != "" {
+		rest := List{}
+		if len(args) > len(f.Params) {
+			rest = append(rest, args[len(f.Params):]...)
+		}
+		localFrame[f.Rest] = rest
+	}
+	for k, v := range kwargs {
+		i := indexOf(f.Params, k)
+		if i < 0 {
+			return nil, fmt.Errorf("%s: unknown keyword argument :%s", name, k)
+		}
+		if i < len(args) {
+			return nil, fmt.Errorf("%s: argument %s given by position and by keyword", name, k)
+		}
+		localFrame[k] = v
+	}
+	var missing []String
+	for _, param := range f.Params[min(len(args), len(f.Params)):] {
+		if _, ok := kwargs[param]; ok {
+			continue
+		}
+		if _, ok := f.Defaults[param]; ok {
+			missing = append(missing, param)
+			continue
+		}
+		if len(kwargs) == 0 {
+			return nil, arityError(name, f, len(args))
+		}
+		return nil, fmt.Errorf("%s: missing argument %s", name, param)
+	}
+	if len(missing) == 0 {
+		return localFrame, nil
+	}
+	depth := len(r.Stack)
+	r.Stack = append(r.Stack, localFrame)
+	defer r.unwind(depth)
+	ctx = setOptionsToContext(ctx, &stepOptions{tailCall: false})
+	for _, param := range missing {
+		v, err := r.Step(ctx, f.Defaults[param])
+		if err != nil {
+			return nil, err
+		}
+		localFrame[param] = v
+	}
+	return localFrame, nil
+}
+
+func indexOf(names []String, name String) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
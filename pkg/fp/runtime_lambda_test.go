@@ -0,0 +1,111 @@
+package fp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCallArgs(t *testing.T) {
+	const defs = `(let f (lambda x (add x 100))) (let g (lambda a b (y 0) (list a b y))) `
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(let h (lambda x (g 7 (f 1) :y x))) (h 5)`, "[7,101,5,]"},
+		{`(let h (lambda x (g 7 (f x)))) (h 5)`, "[7,105,0,]"},
+		{`(let h (lambda x (g :y (f x) 7 x))) (h 5)`, "[7,5,105,]"},
+		{`(let h (lambda x (g :b x :a (f 1)))) (h 5)`, "[101,5,0,]"},
+		{`(let h (lambda x (g * (list 1 2) :y x))) (h 5)`, "[1,2,5,]"},
+		{`(let h (lambda x (g * (list x (f 1)) :y x))) (h 5)`, "[5,101,5,]"},
+		{`(let h (lambda x (g (add x (f 1)) x))) (h 5)`, "[106,5,0,]"},
+	}
+	for _, test := range tests {
+		for _, opts := range [][]Option{nil, {WithParallelEvaluation(4)}} {
+			o, err := run(t, defs+test.source, opts...)
+			if err != nil {
+				t.Errorf("%s: %v", test.source, err)
+				continue
+			}
+			if got := o.String(); got != test.want {
+				t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+			}
+		}
+	}
+}
+
+func TestArityError(t *testing.T) {
+	const defs = `(let f (lambda x x)) (let g (lambda a b (y 0) (list a b y))) (let h (lambda a * rest a)) (let k (lambda a (b 1) b)) `
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{`(f)`, "f requires 1 argument, got 0"},
+		{`(f 1 2)`, "f requires 1 argument, got 2"},
+		{`(g 1)`, "g requires 2 or 3 arguments, got 1"},
+		{`(g 1 2 3 4)`, "g requires 2 or 3 arguments, got 4"},
+		{`(h)`, "h requires at least 1 argument, got 0"},
+		{`(k)`, "k requires 1 or 2 arguments, got 0"},
+	}
+	for _, test := range tests {
+		_, err := run(t, defs+test.source)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %q", test.source, err, test.err)
+		}
+	}
+}
+
+func TestTailCall(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(let c (lambda n acc (case n 0 acc _ (c (sub n 1) (add acc 1))))) (c 3000 0)`, "3000"},
+		{`(let d (lambda n (case n 0 0 _ (add 1 (d (sub n 1)))))) (d 3000)`, "3000"},
+	}
+	for _, test := range tests {
+		o, err := run(t, test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestStackBalance(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(let f (lambda x (add x 1))) (f 1) (add 1 2)`, "3"},
+		{`(let z 5) (let f (lambda x (kaboom))) (f 1) (add z 2)`, "7"},
+		{`(let f (lambda x (tail (kaboom) 1))) (list (f 1) (f 2))`, "[1,1,]"},
+		{`(with (y 1) (tail (kaboom) (add 1 2)))`, "3"},
+		{`(let f (lambda x (with (y 1) (kaboom)))) (f 1) (add 1 2)`, "3"},
+	}
+	for _, test := range tests {
+		exprs, err := ParseSource(test.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := NewStdRuntime()
+		var o Object
+		for _, expr := range exprs {
+			if o, err = r.Step(context.Background(), expr); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+		if len(r.Stack) != 1 {
+			t.Errorf("%s: %d frames left, want 1", test.source, len(r.Stack))
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package fp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Quote : unevaluated expression, given to macros and returned by quote and quasiquote
+type Quote struct {
+	Expr Expr
+}
+
+func (q Quote) String() string {
+	return q.Expr.String()
+}
+
+func (q Quote) MustTypeObject() {}
+
+// Macro : lambda called with unevaluated arguments, its result is evaluated in place of the call
+type Macro struct {
+	Name   String
+	Lambda Lambda
+}
+
+func (m Macro) String() string {
+	return fmt.Sprintf("(macro %s)", m.Lambda.String())
+}
+
+func (m Macro) MustTypeObject() {}
+
+// objectToExpr : code represented by o, used to insert values into a quasiquote or a macro expansion
+func objectToExpr(o Object) (Expr, error) {
+	switch o := o.(type) {
+	case Quote:
+		return o.Expr, nil
+	case Int, Wildcard, Unwrap:
+		return NameExpr(o.String()), nil
+	case String:
+		b, err := json.Marshal(string(o))
+		if err != nil {
+			return nil, err
+		}
+		return NameExpr(b), nil
+	case List:
+		e := LambdaExpr{Name: "list"}
+		for _, elem := range o {
+			arg, err := objectToExpr(elem)
+			if err != nil {
+				return nil, err
+			}
+			e.Args = append(e.Args, arg)
+		}
+		return e, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to expression", getType(o))
+	}
+}
+
+// gensymKey : key of the gensym counter in Runtime.values, shared with forks
+type gensymKey struct{}
+
+// gensym : fresh name, x#1 for prefix x
+func (r *Runtime) gensym(prefix string) NameExpr {
+	v, _ := r.values.LoadOrStore(gensymKey{}, new(atomic.Uint64))
+	return NameExpr(fmt.Sprintf("%s#%d", prefix, v.(*atomic.Uint64).Add(1)))
+}
+
+// quasiquote : replace (unquote x) by the value of x and (unquote-splicing l) by the elements of l,
+// names ending with # become the same fresh name in the whole template
+func (r *Runtime) quasiquote(ctx context.Context, expr Expr, names map[NameExpr]NameExpr) (Expr, error) {
+	switch e := expr.(type) {
+	case NameExpr:
+		return r.autoGensym(e, names), nil
+	case LambdaExpr:
+		switch e.Name {
+		case "unquote":
+			if len(e.Args) != 1 {
+				return nil, fmt.Errorf("unquote requires 1 argument")
+			}
+			v, err := r.Step(ctx, e.Args[0])
+			if err != nil {
+				return nil, err
+			}
+			return objectToExpr(v)
+		case "unquote-splicing":
+			return nil, fmt.Errorf("unquote-splicing must be an argument of a call")
+		}
+		out := LambdaExpr{Name: e.Name}
+		args := e.Args
+		if e.Name == "," || e.Name == ",@" {
+			// (,f x) : the name of the call is unquoted
+			if e.Name == ",@" || len(args) == 0 {
+				return nil, fmt.Errorf("only unquote can be used as the name of a call")
+			}
+			v, err := r.Step(ctx, args[0])
+			if err != nil {
+				return nil, err
+			}
+			name, err := objectToExpr(v)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := name.(NameExpr); !ok {
+				return nil, fmt.Errorf("name of a call must be a name, got %s", name)
+			}
+			out.Name, args = name.(NameExpr), args[1:]
+		} else {
+			out.Name = r.autoGensym(e.Name, names)
+		}
+		for _, arg := range args {
+			if s, ok := arg.(LambdaExpr); ok && s.Name == "unquote-splicing" {
+				if len(s.Args) != 1 {
+					return nil, fmt.Errorf("unquote-splicing requires 1 argument")
+				}
+				v, err := r.Step(ctx, s.Args[0])
+				if err != nil {
+					return nil, err
+				}
+				l, ok := v.(List)
+				if !ok {
+					return nil, fmt.Errorf("unquote-splicing requires a list, got %s", getType(v))
+				}
+				for _, elem := range l {
+					x, err := objectToExpr(elem)
+					if err != nil {
+						return nil, err
+					}
+					out.Args = append(out.Args, x)
+				}
+				continue
+			}
+			x, err := r.quasiquote(ctx, arg, names)
+			if err != nil {
+				return nil, err
+			}
+			out.Args = append(out.Args, x)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("runtime error: unknown expression type")
+	}
+}
+
+// autoGensym : x# becomes the same fresh name everywhere in a quasiquote
+func (r *Runtime) autoGensym(e NameExpr, names map[NameExpr]NameExpr) NameExpr {
+	if len(e) < 2 || !strings.HasSuffix(string(e), "#") {
+		return e
+	}
+	if _, ok := names[e]; !ok {
+		names[e] = r.gensym(string(e[:len(e)-1]))
+	}
+	return names[e]
+}
+
+// lookupMacro : macro bound to name, if any
+func (r *Runtime) lookupMacro(name NameExpr) (Macro, bool) {
+	for i := len(r.Stack) - 1; i >= 0; i-- {
+		if o, ok := r.Stack[i][String(name)]; ok {
+			m, ok := o.(Macro)
+			return m, ok
+		}
+	}
+	return Macro{}, false
+}
+
+// expandMacro : call m with the unevaluated arguments of expr and convert the result back to an expression
+func (r *Runtime) expandMacro(ctx context.Context, m Macro, expr LambdaExpr) (Expr, error) {
+	var args []Object
+	var kwargs map[String]Object
+	for i := 0; i < len(expr.Args); i++ {
+		if name, ok := isKeyword(expr.Args[i]); ok && i+1 < len(expr.Args) {
+			if kwargs == nil {
+				kwargs = make(map[String]Object)
+			}
+			kwargs[name] = Quote{Expr: expr.Args[i+1]}
+			i++
+			continue
+		}
+		args = append(args, Quote{Expr: expr.Args[i]})
+	}
+	ctx = setOptionsToContext(ctx, &stepOptions{tailCall: false})
+	o, err := r.callLambda(ctx, String(expr.Name), m.Lambda, args, kwargs, false)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := objectToExpr(o)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", expr.Name, err)
+	}
+	return expanded, nil
+}
+
+// Expand : expand every macro call in expr, macros are looked up in the current stack
+//
+// quote and quasiquote are left untouched, macros defined while evaluating expr are expanded when they are called by Step
+func (r *Runtime) Expand(ctx context.Context, expr Expr) (Expr, error) {
+	return r.expand(ctx, expr, 0)
+}
+
+func (r *Runtime) expand(ctx context.Context, expr Expr, depth int) (Expr, error) {
+	e, ok := expr.(LambdaExpr)
+	if !ok {
+		return expr, nil
+	}
+	for {
+		if depth > r.Options.MaxStackDepth {
+			return nil, StackOverflowError
+		}
+		m, ok := r.lookupMacro(e.Name)
+		if !ok {
+			break
+		}
+		expanded, err := r.expandMacro(ctx, m, e)
+		if err != nil {
+			return nil, err
+		}
+		depth++
+		if e, ok = expanded.(LambdaExpr); !ok {
+			return expanded, nil
+		}
+	}
+	if e.Name == "quote" || e.Name == "quasiquote" {
+		return e, nil
+	}
+	out := LambdaExpr{Name: e.Name, Args: make([]Expr, 0, len(e.Args)), Pos: e.Pos}
+	for _, arg := range e.Args {
+		x, err := r.expand(ctx, arg, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		out.Args = append(out.Args, x)
+	}
+	return out, nil
+}
+
+var quoteModule = Module{
+	Name: "quote",
+	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
+		if err := checkArgs("quote", len(expr.Args), 1, 1); err != nil {
+			return nil, err
+		}
+		return Quote{Expr: expr.Args[0]}, nil
+	},
+	Man: "module: (quote (add 1 2)) - the expression itself, without evaluating it",
+}
+
+var quasiquoteModule = Module{
+	Name: "quasiquote",
+	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
+		if err := checkArgs("quasiquote", len(expr.Args), 1, 1); err != nil {
+			return nil, err
+		}
+		// unquoted expressions are never tail calls, the template is built after them
+		e, err := r.quasiquote(setOptionsToContext(ctx, &stepOptions{}), expr.Args[0], make(map[NameExpr]NameExpr))
+		if err != nil {
+			return nil, err
+		}
+		return Quote{Expr: e}, nil
+	},
+	Man: "module: `(add ,x ,@rest y#) - template of an expression, ,x is replaced by the value of x, ,@rest by the elements of rest, y# by a fresh name",
+}
+
+var defmacroModule = Module{
+	Name: "defmacro",
+	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
+		if err := checkArgs("defmacro", len(expr.Args), 2, -1); err != nil {
+			return nil, err
+		}
+		name, err := r.argName(expr, 0)
+		if err != nil {
+			return nil, err
+		}
+		l, err := lambdaModule.Exec(ctx, r, LambdaExpr{Name: "lambda", Args: expr.Args[1:]})
+		if err != nil {
+			return nil, err
+		}
+		m := Macro{Name: name, Lambda: l.(Lambda)}
+		r.writableFrame(len(r.Stack) - 1)[m.Name] = m
+		return m, nil
+	},
+	Man: "module: (defmacro unless c x y `(case ,c 0 ,x _ ,y)) - define a macro, arguments are given unevaluated, the returned expression is evaluated instead of the call, other names of the template are looked up where it is used, not where the macro is defined",
+}
+
+var macroexpandModule = Module{
+	Name: "macroexpand",
+	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
+		if err := checkArgs("macroexpand", len(expr.Args), 1, 1); err != nil {
+			return nil, err
+		}
+		e, err := r.Expand(ctx, expr.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return Quote{Expr: e}, nil
+	},
+	Man: "module: (macroexpand (unless 1 2 3)) - the expression after expanding every macro",
+}
+
+var gensymExtension = Extension{
+	Name: "gensym",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		prefix := "g"
+		if err := checkArgs("gensym", len(values), 0, 1); err != nil {
+			return nil, err
+		}
+		if len(values) == 1 {
+			s, ok := values[0].(String)
+			if !ok {
+				return nil, fmt.Errorf("first argument must be string")
+			}
+			prefix = string(s)
+		}
+		return Quote{Expr: mustGetExtensionContext(ctx).Runtime().gensym(prefix)}, nil
+	},
+	Man: "module: (gensym \"tmp\") - fresh name, never equal to a name written in the code",
+}
+
+// callExpr : the call represented by a quoted expression
+func callExpr(o Object) (LambdaExpr, error) {
+	q, ok := o.(Quote)
+	if !ok {
+		return LambdaExpr{}, fmt.Errorf("first argument must be expression")
+	}
+	e, ok := q.Expr.(LambdaExpr)
+	if !ok {
+		return LambdaExpr{}, fmt.Errorf("%s is not a call", q.Expr)
+	}
+	return e, nil
+}
+
+var exprNameExtension = Extension{
+	Name: "expr-name",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("expr-name", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		e, err := callExpr(values[0])
+		if err != nil {
+			return nil, err
+		}
+		return Quote{Expr: e.Name}, nil
+	},
+	Man: "module: (expr-name (quote (add 1 2))) - name of a quoted call, here add",
+}
+
+var exprArgsExtension = Extension{
+	Name: "expr-args",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("expr-args", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		e, err := callExpr(values[0])
+		if err != nil {
+			return nil, err
+		}
+		args := List{}
+		for _, arg := range e.Args {
+			args = append(args, Quote{Expr: arg})
+		}
+		return args, nil
+	},
+	Man: "module: (expr-args (quote (add 1 2))) - list of the quoted arguments of a quoted call",
+}
@@ -0,0 +1,82 @@
+package fp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMacroExpansion(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		err    string
+	}{
+		{source: "(defmacro unless c x y `(case ,c 0 ,x _ ,y)) (unless 0 1 2)", want: "1"},
+		// arguments are given unevaluated, only the branch taken is evaluated
+		{source: "(defmacro unless c x y `(case ,c 0 ,x _ ,y)) (unless 1 (div 1 0) 2)", want: "2"},
+		{source: "(defmacro unless c x y `(case ,c 0 ,x _ ,y)) (macroexpand (unless c (f 1) 2))", want: "(case c 0 (f 1) _ 2)"},
+		{source: "(defmacro my-list * xs `(list ,@xs)) (macroexpand (my-list 1 (add 1 2)))", want: "(list 1 (add 1 2))"},
+		{source: "(defmacro my-list * xs `(list ,@xs)) (my-list 1 (add 1 2))", want: "[1,3,]"},
+		// macros used in the expansion are expanded too
+		{source: "(defmacro unless c x y `(case ,c 0 ,x _ ,y)) (defmacro when c x `(unless ,c 0 ,x)) (macroexpand (when 1 2))", want: "(case 1 0 0 _ 2)"},
+		// x# is the same fresh name in the whole template
+		{source: "(defmacro swap! a b `(with (tmp# ,a) (tail (let ,a ,b) (let ,b tmp#)))) (macroexpand (swap! x y))", want: "(with (tmp#1 x) (tail (let x y) (let y tmp#1)))"},
+		{source: "(defmacro square x `(with (v# ,x) (mul v# v#))) (square (add 1 2))", want: "9"},
+		// a variable named v in the caller is not captured
+		{source: "(defmacro square x `(with (v# ,x) (mul v# v#))) (let v 3) (square (add v 1))", want: "16"},
+		// other names of the template are looked up where the macro is used
+		{source: "(defmacro sq x `(mul ,x ,x)) (with (mul add) (sq 3))", want: "6"},
+		{source: "(defmacro twice x `(tail ,x ,x)) (let n 0) (twice (let n (add n 1))) n", want: "2"},
+		{source: "(expr-name (quote (add 1 2)))", want: "add"},
+		{source: "(expr-args (quote (add 1 (sub 2 3))))", want: "[1,(sub 2 3),]"},
+		{source: "(defmacro m x `(add ,@x)) (m 1)", err: "unquote-splicing requires a list"},
+	}
+	for _, test := range tests {
+		o, err := run(t, test.source)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.source, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestGensym(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(gensym)`, "g#1"},
+		{`(gensym "tmp")`, "tmp#1"},
+		{`(gensym "a") (gensym "a")`, "a#2"},
+		// every expansion gets fresh names
+		{"(defmacro m `(list (quote x#) (quote y#) (quote x#))) (m) (m)", "[x#3,y#4,x#3,]"},
+	}
+	for _, test := range tests {
+		o, err := run(t, test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+	// forks share the counter so names made on forks never collide
+	r := NewStdRuntime()
+	eval(t, r, `(gensym "f")`)
+	if got := eval(t, r.Fork(), `(gensym "f")`).String(); got != "f#2" {
+		t.Errorf("gensym on a fork: got %s, want f#2", got)
+	}
+	if got := eval(t, r, `(gensym "f")`).String(); got != "f#3" {
+		t.Errorf("gensym after a fork: got %s, want f#3", got)
+	}
+}
@@ -0,0 +1,189 @@
+package fp
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultMemoSize : number of results kept by (memo f) without size
+const defaultMemoSize = 1000
+
+// Memo : pure lambda with a cache of its results by arguments, see memo
+type Memo struct {
+	Lambda Lambda
+	cache  *memoCache
+}
+
+func (m Memo) String() string {
+	return fmt.Sprintf("(memo %s)", m.Lambda.String())
+}
+
+func (m Memo) MustTypeObject() {}
+
+// memoCache : least recently used results, shared by every copy of the Memo and safe for concurrent calls
+type memoCache struct {
+	mtx       sync.Mutex
+	size      int
+	entries   map[string]*list.Element
+	order     *list.List // most recently used first
+	hits      int
+	misses    int
+	evictions int
+}
+
+type memoEntry struct {
+	key   string
+	value Object
+}
+
+func newMemo(f Lambda, size int) Memo {
+	return Memo{
+		Lambda: f,
+		cache: &memoCache{
+			size:    size,
+			entries: make(map[string]*list.Element),
+			order:   list.New(),
+		},
+	}
+}
+
+func (c *memoCache) get(key string) (Object, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(e)
+	return e.Value.(*memoEntry).value, true
+}
+
+func (c *memoCache) put(key string, value Object) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if e, ok := c.entries[key]; ok {
+		// computed at the same time by another goroutine
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&memoEntry{key: key, value: value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoEntry).key)
+		c.evictions++
+	}
+}
+
+// writeMemoKey : structural encoding of o, only Int, String and List of them can be cached
+func writeMemoKey(b *strings.Builder, o Object) bool {
+	switch o := o.(type) {
+	case Int:
+		b.WriteString("i" + strconv.Itoa(int(o)) + ";")
+	case String:
+		b.WriteString("s" + strconv.Itoa(len(o)) + ":" + string(o))
+	case List:
+		b.WriteString("l" + strconv.Itoa(len(o)) + ":")
+		for _, elem := range o {
+			if !writeMemoKey(b, elem) {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// memoKey : key of a call, ok is false if an argument cannot be cached
+func memoKey(args []Object, kwargs map[String]Object) (string, bool) {
+	b := &strings.Builder{}
+	for _, arg := range args {
+		if !writeMemoKey(b, arg) {
+			return "", false
+		}
+	}
+	names := make([]string, 0, len(kwargs))
+	for name := range kwargs {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(":" + name + "=")
+		if !writeMemoKey(b, kwargs[String(name)]) {
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// call : result from the cache, or call the lambda and keep its result
+func (m Memo) call(ctx context.Context, r *Runtime, name String, args []Object, kwargs map[String]Object, tailCall bool) (Object, error) {
+	key, ok := memoKey(args, kwargs)
+	if !ok {
+		return r.callLambda(ctx, name, m.Lambda, args, kwargs, tailCall)
+	}
+	if v, ok := m.cache.get(key); ok {
+		return v, nil
+	}
+	v, err := r.callLambda(ctx, name, m.Lambda, args, kwargs, tailCall)
+	if err != nil {
+		return nil, err
+	}
+	m.cache.put(key, v)
+	return v, nil
+}
+
+var memoExtension = Extension{
+	Name: "memo",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("memo", len(values), 1, 2); err != nil {
+			return nil, err
+		}
+		f, ok := values[0].(Lambda)
+		if !ok {
+			return nil, fmt.Errorf("first argument must be lambda")
+		}
+		if p := mustGetExtensionContext(ctx).Runtime().PurityOf(f); p != Pure {
+			return nil, fmt.Errorf("memo requires a pure function, got %s", p)
+		}
+		size := Int(defaultMemoSize)
+		if len(values) == 2 {
+			if size, ok = values[1].(Int); !ok || size <= 0 {
+				return nil, fmt.Errorf("second argument must be positive integer")
+			}
+		}
+		return newMemo(f, int(size)), nil
+	},
+	Man: "module: (let fib (memo fib 100)) - cache the results of a pure function for the last 100 arguments, recursive calls use the cache once rebound",
+}
+
+var memoStatsExtension = Extension{
+	Name: "memo-stats",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("memo-stats", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		m, ok := values[0].(Memo)
+		if !ok {
+			return nil, fmt.Errorf("first argument must be memo")
+		}
+		m.cache.mtx.Lock()
+		defer m.cache.mtx.Unlock()
+		return Dict{
+			String("hits"):      Int(m.cache.hits),
+			String("misses"):    Int(m.cache.misses),
+			String("evictions"): Int(m.cache.evictions),
+			String("len"):       Int(m.cache.order.Len()),
+			String("size"):      Int(m.cache.size),
+		}, nil
+	},
+	Man: "module: (memo-stats f) - dict of hits, misses, evictions, len and size of the cache of a memo",
+}
@@ -0,0 +1,81 @@
+package fp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMemoEviction(t *testing.T) {
+	m := newMemo(Lambda{}, 2)
+	for _, key := range []string{"a", "b", "a", "c"} {
+		if _, ok := m.cache.get(key); !ok {
+			m.cache.put(key, String(key))
+		}
+	}
+	// b was the least recently used when c was added
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := m.cache.entries[key]; ok != want {
+			t.Errorf("%s cached: got %v, want %v", key, ok, want)
+		}
+	}
+	if m.cache.hits != 1 || m.cache.misses != 3 || m.cache.evictions != 1 || m.cache.order.Len() != 2 {
+		t.Errorf("got %d hits, %d misses, %d evictions, len %d, want 1, 3, 1, 2", m.cache.hits, m.cache.misses, m.cache.evictions, m.cache.order.Len())
+	}
+}
+
+func TestMemoStats(t *testing.T) {
+	const fib = `(let fib (lambda n (case n 0 0 1 1 _ (add (fib (sub n 1)) (fib (sub n 2))))))`
+	tests := []struct {
+		source string
+		want   Dict
+	}{
+		{fib + `(let fib (memo fib)) (fib 10) (memo-stats fib)`, Dict{
+			String("hits"): Int(8), String("misses"): Int(11), String("evictions"): Int(0), String("len"): Int(11), String("size"): Int(1000),
+		}},
+		{fib + `(let fib (memo fib)) (fib 10) (fib 10) (memo-stats fib)`, Dict{
+			String("hits"): Int(9), String("misses"): Int(11), String("evictions"): Int(0), String("len"): Int(11), String("size"): Int(1000),
+		}},
+		{fib + `(let fib (memo fib 3)) (fib 10) (memo-stats fib)`, Dict{
+			String("hits"): Int(8), String("misses"): Int(11), String("evictions"): Int(8), String("len"): Int(3), String("size"): Int(3),
+		}},
+		// arguments that cannot be cached are not counted
+		{`(let f (memo (lambda x (list x)))) (f (list 1 (lambda y y))) (f "ab") (memo-stats f)`, Dict{
+			String("hits"): Int(0), String("misses"): Int(1), String("evictions"): Int(0), String("len"): Int(1), String("size"): Int(1000),
+		}},
+	}
+	for _, test := range tests {
+		o, err := run(t, test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		got, ok := o.(Dict)
+		if !ok || len(got) != len(test.want) {
+			t.Errorf("%s: got %s, want %s", test.source, o, test.want)
+			continue
+		}
+		for k, v := range test.want {
+			if got[k] != v {
+				t.Errorf("%s: %s is %s, want %s", test.source, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestMemoErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{`(memo (lambda x (print x)))`, "memo requires a pure function, got effectful"},
+		{`(memo (lambda x x) 0)`, "second argument must be positive integer"},
+		{`(memo add)`, "first argument must be lambda"},
+		{`(memo-stats (lambda x x))`, "first argument must be memo"},
+	}
+	for _, test := range tests {
+		_, err := run(t, test.source)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.source, err, test.err)
+		}
+	}
+}
@@ -4,47 +4,73 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
+	"io"
+	"strings"
 )
 
 type Extension struct {
 	Name String
 	Exec func(ctx context.Context, values ...Object) (Object, error)
 	Man  string
+	Pure bool // no side effect, arguments may be evaluated in parallel, see WithParallelEvaluation
 }
 
 func makeModuleFromExtension(e Extension) Module {
 	return Module{
 		Name: e.Name,
 		Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
-			args, err := r.stepMany(ctx, expr.Args...)
+			var args []Object
+			var err error
+			if e.Pure {
+				args, err = r.stepPureArgs(ctx, nil, expr.Args...)
+			} else {
+				args, err = r.stepArgs(ctx, expr.Args...)
+			}
 			if err != nil {
 				return nil, err
 			}
-			var unwrappedArgs []Object
-			i := 0
-			for i < len(args) {
-				if _, ok := args[i].(Unwrap); ok {
-					if i+1 >= len(args) {
-						return nil, errors.New("unwrapping arguments must be a list")
-					}
-					argsList, ok := args[i+1].(List)
-					if !ok {
-						return nil, errors.New("unwrapping arguments must be a list")
-					}
-					for _, elem := range argsList {
-						unwrappedArgs = append(unwrappedArgs, elem)
-					}
-					i += 2
-				} else {
-					unwrappedArgs = append(unwrappedArgs, args[i])
-					i++
-				}
-			}
-			return e.Exec(ctx, unwrappedArgs...)
+			return e.Exec(newExtensionContext(ctx, r, expr), args...)
 		},
-		Man: e.Man,
+		Man:  e.Man,
+		Pure: e.Pure,
+	}
+}
+
+// stepArgs : evaluate arguments then replace every (* list) by the elements of the list
+func (r *Runtime) stepArgs(ctx context.Context, exprList ...Expr) ([]Object, error) {
+	args, err := r.stepMany(ctx, exprList...)
+	if err != nil {
+		return nil, err
+	}
+	return r.unwrapArgs(ctx, args)
+}
+
+// unwrapArgs : replace every (* list) by the elements of the list
+func (r *Runtime) unwrapArgs(ctx context.Context, args []Object) ([]Object, error) {
+	var unwrappedArgs []Object
+	i := 0
+	for i < len(args) {
+		if _, ok := args[i].(Unwrap); ok {
+			if i+1 >= len(args) {
+				return nil, errors.New("unwrapping arguments must be a list")
+			}
+			argsList, ok, err := toList(ctx, r, args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, errors.New("unwrapping arguments must be a list")
+			}
+			for _, elem := range argsList {
+				unwrappedArgs = append(unwrappedArgs, elem)
+			}
+			i += 2
+		} else {
+			unwrappedArgs = append(unwrappedArgs, args[i])
+			i++
+		}
 	}
+	return unwrappedArgs, nil
 }
 
 func (r *Runtime) LoadExtension(e Extension) *Runtime {
@@ -54,32 +80,118 @@ func (r *Runtime) LoadExtension(e Extension) *Runtime {
 var letModule = Module{
 	Name: "let",
 	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
-		if len(expr.Args) < 2 {
-			return nil, fmt.Errorf("not enough arguments for let")
+		if err := checkArgs("let", len(expr.Args), 2, -1); err != nil {
+			return nil, err
+		}
+		name, err := r.argName(expr, 0)
+		if err != nil {
+			return nil, err
 		}
-		name := String(expr.Args[0].(NameExpr))
 		outputs, err := r.stepMany(ctx, expr.Args[1:]...)
 		if err != nil {
 			return nil, err
 		}
-		r.Stack[len(r.Stack)-1][name] = outputs[len(outputs)-1]
+		r.writableFrame(len(r.Stack) - 1)[name] = outputs[len(outputs)-1]
 		return outputs[len(outputs)-1], nil
 	},
 	Man: "module: (let x 3) - assign value 3 to local variable x",
 }
 
+// binding : (name expr) in with and letrec
+type binding struct {
+	name String
+	expr Expr
+}
+
+// parseBindings : every argument but the last one is a binding, the last one is the body
+func (r *Runtime) parseBindings(expr LambdaExpr) ([]binding, Expr, error) {
+	if len(expr.Args) < 1 {
+		return nil, nil, fmt.Errorf("%s requires a body", expr.Name)
+	}
+	var bindings []binding
+	for _, arg := range expr.Args[:len(expr.Args)-1] {
+		b, ok := arg.(LambdaExpr)
+		if !ok || len(b.Args) != 1 {
+			return nil, nil, fmt.Errorf("%s: binding must be of the form (name expr), got %s", expr.Name, arg)
+		}
+		name, ok := r.bindable(b.Name)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: binding must be of the form (name expr), got %s", expr.Name, arg)
+		}
+		bindings = append(bindings, binding{name: name, expr: b.Args[0]})
+	}
+	return bindings, expr.Args[len(expr.Args)-1], nil
+}
+
+var withModule = Module{
+	Name: "with",
+	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
+		bindings, body, err := r.parseBindings(expr)
+		if err != nil {
+			return nil, err
+		}
+		// bindings live in a new frame, popped after the body.
+		// it starts as a copy of the current frame since lambdas only capture the top frame
+		depth := len(r.Stack)
+		r.Stack = append(r.Stack, make(Frame).Update(r.Stack[depth-1]))
+		defer r.unwind(depth)
+		for _, b := range bindings {
+			v, err := r.Step(setOptionsToContext(ctx, &stepOptions{}), b.expr)
+			if err != nil {
+				return nil, err
+			}
+			r.writableFrame(depth)[b.name] = v
+		}
+		return r.Step(ctx, body)
+	},
+	Man: "module: (with (x 1) (y (add x 1)) (mul x y)) - bind variables one after another, only visible in the body",
+}
+
+var letrecModule = Module{
+	Name: "letrec",
+	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
+		bindings, body, err := r.parseBindings(expr)
+		if err != nil {
+			return nil, err
+		}
+		depth := len(r.Stack)
+		r.Stack = append(r.Stack, make(Frame).Update(r.Stack[depth-1]))
+		defer r.unwind(depth)
+		values := make(Frame)
+		for _, b := range bindings {
+			v, err := r.Step(setOptionsToContext(ctx, &stepOptions{}), b.expr)
+			if err != nil {
+				return nil, err
+			}
+			values[b.name] = v
+			r.writableFrame(depth)[b.name] = v
+		}
+		// every lambda sees every binding, including itself, so they can call each other
+		for _, v := range values {
+			if l, ok := v.(Lambda); ok {
+				l.Frame.Update(values)
+			}
+		}
+		return r.Step(ctx, body)
+	},
+	Man: "module: (letrec (even (lambda n (case n 0 1 _ (odd (sub n 1))))) (odd (lambda n (case n 0 0 _ (even (sub n 1))))) (even 10)) - bind mutually recursive local functions",
+}
+
 var delModule = Module{
 	Name: "del",
 	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
-		if len(expr.Args) < 1 {
-			return nil, fmt.Errorf("not enough arguments for del")
+		if err := checkArgs("del", len(expr.Args), 1, -1); err != nil {
+			return nil, err
+		}
+		name, err := r.argName(expr, 0)
+		if err != nil {
+			return nil, err
 		}
-		name := String(expr.Args[0].(NameExpr))
-		_, err := r.stepMany(ctx, expr.Args[1:]...)
+		_, err = r.stepMany(ctx, expr.Args[1:]...)
 		if err != nil {
 			return nil, err
 		}
-		delete(r.Stack[len(r.Stack)-1], name)
+		delete(r.writableFrame(len(r.Stack)-1), name)
 		return nil, nil
 	},
 	Man: "module: (del x) - delete variable x",
@@ -88,36 +200,48 @@ var delModule = Module{
 var lambdaModule = Module{
 	Name: "lambda",
 	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
-		v := Lambda{
-			Params: nil,
-			Impl:   nil,
-			Frame:  nil,
+		if len(expr.Args) == 0 {
+			return nil, fmt.Errorf("lambda requires a body")
+		}
+		params, defaults, rest, err := r.parseParams(expr.Args[:len(expr.Args)-1])
+		if err != nil {
+			return nil, err
 		}
-		for i := 0; i < len(expr.Args)-1; i++ {
-			paramName := String(expr.Args[i].(NameExpr))
-			v.Params = append(v.Params, paramName)
+		v := Lambda{
+			Params:   params,
+			Defaults: defaults,
+			Rest:     rest,
+			Impl:     expr.Args[len(expr.Args)-1],
+			Frame:    make(Frame).Update(r.Stack[len(r.Stack)-1]),
+			purity:   &purityCache{},
 		}
-		v.Impl = expr.Args[len(expr.Args)-1]
-		v.Frame = make(Frame).Update(r.Stack[len(r.Stack)-1])
 		return v, nil
 	},
-	Man: "module: (lambda x y (add x y) - declare a function",
+	Man: "module: (lambda x (y 2) * rest (add x y * rest)) - declare a function, y is optional, rest is the list of remaining arguments, call with (f 1 :y 3)",
 }
 
 var caseModule = Module{
 	Name: "case",
 	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
-		cond, err := r.Step(ctx, expr.Args[0])
+		if err := checkArgs("case", len(expr.Args), 1, -1); err != nil {
+			return nil, err
+		}
+		if len(expr.Args)%2 == 0 {
+			return nil, fmt.Errorf("case: pattern %s has no result", expr.Args[len(expr.Args)-1])
+		}
+		// the value and the patterns are never tail calls, the result is evaluated after them
+		valueCtx := setOptionsToContext(ctx, &stepOptions{})
+		cond, err := r.Step(valueCtx, expr.Args[0])
 		if err != nil {
 			return nil, err
 		}
 		i, err := func() (int, error) {
 			for i := 1; i < len(expr.Args); i += 2 {
-				comp, err := r.Step(ctx, expr.Args[i])
+				comp, err := r.Step(valueCtx, expr.Args[i])
 				if err != nil {
 					return 0, err
 				}
-				if _, ok := comp.(Wildcard); ok || comp == cond {
+				if match(comp, cond) {
 					return i, nil
 				}
 			}
@@ -128,21 +252,31 @@ var caseModule = Module{
 		}
 		return r.Step(ctx, expr.Args[i+1])
 	},
-	Man: "module: (case x 1 2 4 5) - case, if x=1 then return 3, if x=4 the return 5",
+	Man:  "module: (case x 1 2 4 5) - case, if x=1 then return 3, if x=4 the return 5 (patterns like (ok _) or (list 1 _) match structurally)",
+	Pure: true,
 }
 
 var kaboomModule = Module{
 	Name: "kaboom",
 	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
-		r.Stack = r.Stack[0:1]
+		if err := checkArgs("kaboom", len(expr.Args), 0, 0); err != nil {
+			return nil, err
+		}
+		// the frames are emptied, not popped, the calls running kaboom still pop their own frame
+		for i := 1; i < len(r.Stack); i++ {
+			r.Stack[i] = make(Frame)
+		}
 		return nil, nil
 	},
-	Man: "module: (kaboom) - remove everything except global frame",
+	Man: "module: (kaboom) - remove every variable except the global ones",
 }
 
 var doomExtension = Extension{
 	Name: "doom",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("doom", len(values), 0, 0); err != nil {
+			return nil, err
+		}
 		return String(fmt.Sprintf("i told you - we don't have Doom yet")), nil
 	},
 	Man: "module: (doom) - extra modules required https://youtu.be/dQw4w9WgXcQ",
@@ -151,9 +285,13 @@ var doomExtension = Extension{
 var tailExtension = Extension{
 	Name: "tail",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("tail", len(values), 1, -1); err != nil {
+			return nil, err
+		}
 		return values[len(values)-1], nil
 	},
-	Man: "module: (tail (print 1) (print 2) 3) - exec a sequence of expressions and return the last one",
+	Man:  "module: (tail (print 1) (print 2) 3) - exec a sequence of expressions and return the last one",
+	Pure: true,
 }
 
 var addExtension = Extension{
@@ -161,15 +299,16 @@ var addExtension = Extension{
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
 		var sum Int = 0
 		for i := 0; i < len(values); i++ {
-			v, ok := values[i].(Int)
-			if !ok {
-				return nil, fmt.Errorf("adding non-integer values")
+			v, err := argAs[Int]("add", values, i)
+			if err != nil {
+				return nil, err
 			}
 			sum += v
 		}
 		return sum, nil
 	},
-	Man: "module: (add 1 (add 2 3) 3) - exec a sequence of expressions and return the sum",
+	Man:  "module: (add 1 (add 2 3) 3) - exec a sequence of expressions and return the sum",
+	Pure: true,
 }
 
 var mulExtension = Extension{
@@ -177,86 +316,93 @@ var mulExtension = Extension{
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
 		var sum Int = 1
 		for i := 0; i < len(values); i++ {
-			v, ok := values[i].(Int)
-			if !ok {
-				return nil, fmt.Errorf("multiplying non-integer values")
+			v, err := argAs[Int]("mul", values, i)
+			if err != nil {
+				return nil, err
 			}
 			sum *= v
 		}
 		return sum, nil
 	},
-	Man: "module: (mul 1 (add 2 3) 3) - exec a sequence of expressions and return the product",
+	Man:  "module: (mul 1 (add 2 3) 3) - exec a sequence of expressions and return the product",
+	Pure: true,
 }
 
 var subExtension = Extension{
 	Name: "sub",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
-		if len(values) != 2 {
-			return nil, fmt.Errorf("subtract requires 2 arguments")
+		if err := checkArgs("sub", len(values), 2, 2); err != nil {
+			return nil, err
 		}
-		a, ok := values[0].(Int)
-		if !ok {
-			return nil, fmt.Errorf("subtract non-integer value")
+		a, err := argAs[Int]("sub", values, 0)
+		if err != nil {
+			return nil, err
 		}
-		b, ok := values[1].(Int)
-		if !ok {
-			return nil, fmt.Errorf("subtract non-integer value")
+		b, err := argAs[Int]("sub", values, 1)
+		if err != nil {
+			return nil, err
 		}
 		return a - b, nil
 	},
-	Man: "module: (sub 2 (add 1 1)) - exec two expressions and return difference",
+	Man:  "module: (sub 2 (add 1 1)) - exec two expressions and return difference",
+	Pure: true,
 }
 
 var divExtension = Extension{
 	Name: "div",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
-		if len(values) != 2 {
-			return nil, fmt.Errorf("dividing requires 2 arguments")
+		if err := checkArgs("div", len(values), 2, 2); err != nil {
+			return nil, err
 		}
-		a, ok := values[0].(Int)
-		if !ok {
-			return nil, fmt.Errorf("dividing non-integer value")
+		a, err := argAs[Int]("div", values, 0)
+		if err != nil {
+			return nil, err
 		}
-		b, ok := values[1].(Int)
-		if !ok {
-			return nil, fmt.Errorf("dividing non-integer value")
+		b, err := argAs[Int]("div", values, 1)
+		if err != nil {
+			return nil, err
 		}
 		if b == 0 {
 			return nil, fmt.Errorf("division by zero")
 		}
 		return a / b, nil
 	},
-	Man: "module: (div 2 (add 1 1)) - exec two expressions and return ratio",
+	Man:  "module: (div 2 (add 1 1)) - exec two expressions and return ratio",
+	Pure: true,
 }
 
 var modExtension = Extension{
 	Name: "mod",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
-		if len(values) != 2 {
-			return nil, fmt.Errorf("dividing requires 2 arguments")
+		if err := checkArgs("mod", len(values), 2, 2); err != nil {
+			return nil, err
 		}
-		a, ok := values[0].(Int)
-		if !ok {
-			return nil, fmt.Errorf("dividing non-integer value")
+		a, err := argAs[Int]("mod", values, 0)
+		if err != nil {
+			return nil, err
 		}
-		b, ok := values[1].(Int)
-		if !ok {
-			return nil, fmt.Errorf("dividing non-integer value")
+		b, err := argAs[Int]("mod", values, 1)
+		if err != nil {
+			return nil, err
 		}
 		if b == 0 {
 			return nil, fmt.Errorf("division by zero")
 		}
 		return a % b, nil
 	},
-	Man: "module: (mod 2 (add 1 1)) - exec two expressions and return modulo",
+	Man:  "module: (mod 2 (add 1 1)) - exec two expressions and return modulo",
+	Pure: true,
 }
 
 var signExtension = Extension{
 	Name: "sign",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
-		v, ok := values[len(values)-1].(Int)
-		if !ok {
-			return nil, fmt.Errorf("sign non-integer value")
+		if err := checkArgs("sign", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		v, err := argAs[Int]("sign", values, 0)
+		if err != nil {
+			return nil, err
 		}
 		switch {
 		case v > 0:
@@ -267,7 +413,8 @@ var signExtension = Extension{
 			return Int(0), nil
 		}
 	},
-	Man: "module: (sign 3) - exec an expression and return the sign",
+	Man:  "module: (sign 3) - exec an expression and return the sign",
+	Pure: true,
 }
 
 var listExtension = Extension{
@@ -279,15 +426,22 @@ var listExtension = Extension{
 		}
 		return l, nil
 	},
-	Man: "module: (list 1 2 (lambda x (add x 1))) - make a list",
+	Man:  "module: (list 1 2 (lambda x (add x 1))) - make a list",
+	Pure: true,
 }
 
 var appendExtension = Extension{
 	Name: "append",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
-		l, ok := values[0].(List)
+		if err := checkArgs("append", len(values), 1, -1); err != nil {
+			return nil, err
+		}
+		l, ok, err := extensionList(ctx, values[0])
+		if err != nil {
+			return nil, err
+		}
 		if !ok {
-			return nil, fmt.Errorf("first argument must be list")
+			return nil, fmt.Errorf("first argument must be list or stream")
 		}
 		return append(l, values[1:]...), nil
 	},
@@ -297,23 +451,26 @@ var appendExtension = Extension{
 var sliceExtension = Extension{
 	Name: "slice",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
-		if len(values) != 3 {
-			return nil, fmt.Errorf("slice requires 3 arguments")
+		if err := checkArgs("slice", len(values), 3, 3); err != nil {
+			return nil, err
+		}
+		l, ok, err := extensionList(ctx, values[0])
+		if err != nil {
+			return nil, err
 		}
-		l, ok := values[0].(List)
 		if !ok {
-			return nil, fmt.Errorf("first argument must be list")
+			return nil, fmt.Errorf("first argument must be list or stream")
 		}
 		if len(l) < 1 {
 			return nil, fmt.Errorf("empty list")
 		}
-		i, ok := values[1].(Int)
-		if !ok {
-			return nil, fmt.Errorf("second argument must be integer")
+		i, err := argAs[Int]("slice", values, 1)
+		if err != nil {
+			return nil, err
 		}
-		j, ok := values[2].(Int)
-		if !ok {
-			return nil, fmt.Errorf("third argument must be integer")
+		j, err := argAs[Int]("slice", values, 2)
+		if err != nil {
+			return nil, err
 		}
 		length := Int(len(l))
 		if i < 1 || i > length || j < 1 || j > length {
@@ -327,12 +484,29 @@ var sliceExtension = Extension{
 var peekExtension = Extension{
 	Name: "peek",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
-		if len(values) < 2 {
-			return nil, fmt.Errorf("peak requires at least 2 arguments")
+		if err := checkArgs("peek", len(values), 2, -1); err != nil {
+			return nil, err
+		}
+		if d, ok := values[0].(Dict); ok {
+			var outputs List
+			for _, k := range values[1:] {
+				v, ok := d[k]
+				if !ok {
+					return nil, fmt.Errorf("key %v not found", k)
+				}
+				outputs = append(outputs, v)
+			}
+			if len(outputs) == 1 {
+				return outputs[0], nil
+			}
+			return outputs, nil
+		}
+		l, ok, err := extensionList(ctx, values[0])
+		if err != nil {
+			return nil, err
 		}
-		l, ok := values[0].(List)
 		if !ok {
-			return nil, fmt.Errorf("first argument must be list")
+			return nil, fmt.Errorf("first argument must be list, stream or dict")
 		}
 		length := Int(len(l))
 		if length < 1 {
@@ -340,9 +514,9 @@ var peekExtension = Extension{
 		}
 		var outputs List
 		for j := 1; j < len(values); j++ {
-			i, ok := values[j].(Int)
-			if !ok {
-				return nil, fmt.Errorf("second argument must be integer")
+			i, err := argAs[Int]("peek", values, j)
+			if err != nil {
+				return nil, err
 			}
 			if i < 1 || i > length {
 				return nil, fmt.Errorf("list is out of range")
@@ -354,122 +528,79 @@ var peekExtension = Extension{
 		}
 		return outputs, nil
 	},
-	Man: "module: (peek l 3 2) - get elem from list (can get multiple elements) (list is 1-indexing)",
+	Man: "module: (peek l 3 2) - get elem from list or dict (can get multiple elements) (list is 1-indexing)",
 }
 
 var lenExtension = Extension{
 	Name: "len",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
-		if len(values) != 1 {
-			return nil, fmt.Errorf("len requires 1 argument")
+		if err := checkArgs("len", len(values), 1, 1); err != nil {
+			return nil, err
 		}
 		switch v := values[0].(type) {
 		case List:
 			return Int(len(v)), nil
+		case Stream:
+			l, _, err := extensionList(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			return Int(len(l)), nil
 		case Dict:
 			return Int(len(v)), nil
 		default:
-			return nil, fmt.Errorf("first argument must be list or dict")
+			return nil, fmt.Errorf("first argument must be list, stream or dict")
 		}
 	},
 	Man: "module: (len l) - get length of a list of dict",
 }
 
-// mapModule - TODO make map parallel by make a copy of the latest frame, reuse other frames, call in parallel
-var mapModule = Module{
+// mapArity : map calls f with 1 argument
+func mapArity(f Lambda) error {
+	if len(f.Params)-len(f.Defaults) > 1 || len(f.Params) == 0 && f.Rest == "" {
+		return fmt.Errorf("map function requires 1 argument")
+	}
+	return nil
+}
+
+var mapExtension = Extension{
 	Name: "map",
-	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
-		if len(expr.Args) != 2 {
-			return nil, fmt.Errorf("map requires 2 arguments")
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("map", len(values), 2, 2); err != nil {
+			return nil, err
 		}
-		l1, err := r.Step(ctx, expr.Args[0])
+		l, ok, err := extensionList(ctx, values[0])
 		if err != nil {
 			return nil, err
 		}
-		l, ok := l1.(List)
 		if !ok {
-			return nil, fmt.Errorf("first argument must be list")
-		}
-		f1, err := r.Step(ctx, expr.Args[1])
-		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("first argument must be list or stream")
 		}
-		var outputs List
-		switch f := f1.(type) {
+		switch f := values[1].(type) {
 		case Lambda:
-			if len(f.Params) != 1 {
-				return nil, fmt.Errorf("map function requires 1 argument")
+			if err := mapArity(f); err != nil {
+				return nil, err
 			}
-			for _, v := range l {
-				// 2. add argument to local Frame
-				localFrame := make(Frame).Update(f.Frame)
-				localFrame[f.Params[0]] = v
-				// 3. push Frame to Stack
-				r.Stack = append(r.Stack, localFrame)
-				// 4. exec function
-				o, err := r.Step(ctx, f.Impl)
-				// 5. pop Frame from Stack
-				r.Stack = r.Stack[:len(r.Stack)-1]
-				// 6. append o
-				if err != nil {
-					return nil, err
-				}
-				outputs = append(outputs, o)
+		case Memo:
+			if err := mapArity(f.Lambda); err != nil {
+				return nil, err
 			}
 		case Module:
-			for _, v := range l {
-				// 2. add argument to local Frame
-				localFrame := make(Frame)
-				localFrame["x"] = v // dummy variable
-				// 3. make dummy expr and exec
-				o, err := f.Exec(ctx, r, LambdaExpr{
-					Name: "",
-					Args: []Expr{NameExpr("x")}, // dummy variable
-				})
-				// 5. pop Frame from Stack
-				r.Stack = r.Stack[:len(r.Stack)-1]
-				// 6. append o
-				if err != nil {
-					return nil, err
-				}
-				outputs = append(outputs, o)
-			}
 		default:
 			return nil, fmt.Errorf("runtime error: map module requires a function")
 		}
+		ec := mustGetExtensionContext(ctx)
+		outputs, err := ec.Runtime().parallelMap(ec, values[1], l)
+		if err != nil {
+			return nil, err
+		}
 		return outputs, nil
 	},
-	Man: "module: (map l (lambda y (add 1 y))) - map or for loop",
+	Man: "module: (map l (lambda y (add 1 y))) - map or for loop, in parallel if enabled and the function is not effectful",
 }
 
 // TODO - implement map filter reduce
 
-var rangeExtension = Extension{
-	Name: "range",
-	Exec: func(ctx context.Context, values ...Object) (Object, error) {
-		if len(values) < 2 {
-			return nil, fmt.Errorf("range requires at least 2 arguments")
-		}
-		low, ok := values[0].(Int)
-		if !ok {
-			return nil, fmt.Errorf("first argument must be integer")
-		}
-		high, ok := values[1].(Int)
-		if !ok {
-			return nil, fmt.Errorf("second argument must be integer")
-		}
-		if low > high {
-			return nil, nil
-		}
-		var list List
-		for i := low; i <= high; i++ {
-			list = append(list, i)
-		}
-		return list, nil
-	},
-	Man: "module: (range 1 10) - return [1, 2, ..., 10]",
-}
-
 var typeExtension = Extension{
 	Name: "type",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
@@ -482,14 +613,18 @@ var typeExtension = Extension{
 		}
 		return types, nil
 	},
-	Man: "module: (type x 1 (lambda y (add 1 y))) - get types of objects (can get multiple ones)",
+	Man:  "module: (type x 1 (lambda y (add 1 y))) - get types of objects (can get multiple ones)",
+	Pure: true,
 }
 
-var stackModule = Module{
+var stackExtension = Extension{
 	Name: "stack",
-	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("stack", len(values), 0, 0); err != nil {
+			return nil, err
+		}
 		var stack List
-		for _, f := range r.Stack {
+		for _, f := range mustGetExtensionContext(ctx).Runtime().Stack {
 			frame := make(Dict)
 			for k, v := range f {
 				frame[String(k)] = v
@@ -504,19 +639,90 @@ var stackModule = Module{
 var printExtension = Extension{
 	Name: "print",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		w := mustGetExtensionContext(ctx).Stdout()
 		for _, v := range values {
-			fmt.Printf("%v ", v)
+			_, _ = fmt.Fprintf(w, "%v ", v)
 		}
-		fmt.Println()
+		_, _ = fmt.Fprintln(w)
 		return Int(len(values)), nil
 	},
 	Man: "module: (print 1 x (lambda 3)) - print values",
 }
 
+func fprintln(w io.Writer, values []Object) {
+	for i, v := range values {
+		if i > 0 {
+			_, _ = fmt.Fprint(w, " ")
+		}
+		_, _ = fmt.Fprintf(w, "%v", v)
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+var printlnExtension = Extension{
+	Name: "println",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		fprintln(mustGetExtensionContext(ctx).Stdout(), values)
+		return Int(len(values)), nil
+	},
+	Man: "module: (println \"x =\" x) - print values separated by spaces and end the line",
+}
+
+var eprintExtension = Extension{
+	Name: "eprint",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		fprintln(mustGetExtensionContext(ctx).Stderr(), values)
+		return Int(len(values)), nil
+	},
+	Man: "module: (eprint \"error\" x) - print values to stderr",
+}
+
+var readLineExtension = Extension{
+	Name: "read-line",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("read-line", len(values), 0, 0); err != nil {
+			return nil, err
+		}
+		line, err := mustGetExtensionContext(ctx).Runtime().stdinReader().ReadString('\n')
+		if err == io.EOF && len(line) > 0 {
+			err = nil // last line without line break
+		}
+		if err == io.EOF {
+			return nil, fmt.Errorf("read-line: end of input")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return String(strings.TrimRight(line, "\r\n")), nil
+	},
+	Man: "module: (read-line) - read a line from stdin without the line break",
+}
+
 var timeExtension = Extension{
 	Name: "time",
 	Exec: func(ctx context.Context, values ...Object) (Object, error) {
-		return Int(time.Now().UnixNano()), nil
+		if err := checkArgs("time", len(values), 0, 0); err != nil {
+			return nil, err
+		}
+		return Int(mustGetExtensionContext(ctx).Runtime().Options.Clock().UnixNano()), nil
 	},
 	Man: "(time) - get current time",
 }
+
+var randExtension = Extension{
+	Name: "rand",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("rand", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		n, err := argAs[Int]("rand", values, 0)
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("first argument must be positive")
+		}
+		return Int(mustGetExtensionContext(ctx).Runtime().Options.Rand.Intn(int(n))), nil
+	},
+	Man: "module: (rand 6) - get a random integer in [0, 6)",
+}
@@ -0,0 +1,80 @@
+package fp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWithScope(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		err    string
+	}{
+		{source: `(with (x 1) (y (add x 1)) (mul x y))`, want: "2"},
+		// bindings are only visible in the body
+		{source: `(with (x 1) x) x`, err: "object not found x"},
+		{source: `(let x 1) (with (x 2) x)`, want: "2"},
+		{source: `(let x 1) (with (x 2) x) x`, want: "1"},
+		// let in the body binds in the frame of with
+		{source: `(let x 1) (with (y 2) (let x y)) x`, want: "1"},
+		{source: `(with (y 2) (let z y)) z`, err: "object not found z"},
+		// the body sees the variables of the enclosing function
+		{source: `(let f (lambda a (with (b 2) (add a b)))) (f 1)`, want: "3"},
+		// lambdas made in the body capture the bindings
+		{source: `(let g (with (k 10) (lambda x (add x k)))) (g 1)`, want: "11"},
+		{source: `(with (x) x)`, err: "with: binding must be of the form (name expr), got (x)"},
+		{source: `(with)`, err: "with requires a body"},
+	}
+	for _, test := range tests {
+		o, err := run(t, test.source)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.source, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestLetrecScope(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		err    string
+	}{
+		{source: `(letrec (even (lambda n (case n 0 1 _ (odd (sub n 1))))) (odd (lambda n (case n 0 0 _ (even (sub n 1))))) (list (even 10) (odd 7)))`, want: "[1,1,]"},
+		{source: `(letrec (f (lambda n (case n 0 0 _ (add n (f (sub n 1)))))) (f 4))`, want: "10"},
+		// local functions are not visible after the body
+		{source: `(letrec (f (lambda n n)) (f 1)) (f 1)`, err: "object not found f"},
+		// and do not rebind outer functions
+		{source: `(let f (lambda n 0)) (letrec (f (lambda n 1)) (f 2)) (f 2)`, want: "0"},
+		// returned local functions still call each other
+		{source: `(let even (letrec (even (lambda n (case n 0 1 _ (odd (sub n 1))))) (odd (lambda n (case n 0 0 _ (even (sub n 1))))) even)) (even 4)`, want: "1"},
+		{source: `(letrec (x 1) (y (add x 1)) y)`, want: "2"},
+		{source: `(letrec ("f" 1) 1)`, err: `letrec: binding must be of the form (name expr), got ("f" 1)`},
+	}
+	for _, test := range tests {
+		o, err := run(t, test.source)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.source, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+}
@@ -23,10 +23,28 @@ func getType(o Object) String {
 		return "Lambda"
 	case Module:
 		return "Module"
+	case Memo:
+		return "Memo"
 	case List:
 		return "List"
 	case Dict:
 		return "Dict"
+	case Result:
+		return "Result"
+	case Optional:
+		return "Option"
+	case Stream:
+		return "Stream"
+	case Promise:
+		return "Promise"
+	case Future:
+		return "Future"
+	case Channel:
+		return "Channel"
+	case Quote:
+		return "Expr"
+	case Macro:
+		return "Macro"
 	case Wildcard:
 		return "Wildcard"
 	case Unwrap:
@@ -52,7 +70,7 @@ func (d Dict) String() string {
 	s := ""
 	s += "{"
 	for k, v := range d {
-		s += fmt.Sprintf("%s -> %s,", k.String(), v.String())
+		s += fmt.Sprintf("%v -> %v,", k, v)
 	}
 	s += "}"
 	return s
@@ -85,16 +103,26 @@ func (s String) String() string {
 func (s String) MustTypeObject() {}
 
 type Lambda struct {
-	Params []String `json:"params,omitempty"`
-	Impl   Expr     `json:"impl,omitempty"`
-	Frame  Frame    `json:"frame,omitempty"`
+	Params   []String        `json:"params,omitempty"`
+	Defaults map[String]Expr `json:"defaults,omitempty"` // default values of optional params
+	Rest     String          `json:"rest,omitempty"`     // name of the list of remaining arguments
+	Impl     Expr            `json:"impl,omitempty"`
+	Frame    Frame           `json:"frame,omitempty"`
+	purity   *purityCache    // shared by the copies of the lambda, see Runtime.lambdaPurity
 }
 
 func (l Lambda) String() string {
 	s := "(lambda "
 	for _, param := range l.Params {
+		if d, ok := l.Defaults[param]; ok {
+			s += "(" + param.String() + " " + d.String() + ") "
+			continue
+		}
 		s += param.String() + " "
 	}
+	if l.Rest != "" {
+		s += "* " + l.Rest.String() + " "
+	}
 	s += l.Impl.String()
 	s += ")"
 	return s
@@ -106,6 +134,7 @@ type Module struct {
 	Name String `json:"name,omitempty"`
 	Exec func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error)
 	Man  string `json:"man,omitempty"`
+	Pure bool   `json:"-"` // no side effect other than evaluating its arguments, see Extension.Pure
 }
 
 func (m Module) String() string {
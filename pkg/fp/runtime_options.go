@@ -0,0 +1,118 @@
+package fp
+
+import (
+	"io"
+	"math/rand"
+	"time"
+)
+
+// Options : behaviours of a Runtime, set by Option in NewCoreRuntime, NewBasicRuntime, NewStdRuntime
+type Options struct {
+	MaxStackDepth        int              // Step returns StackOverflowError beyond this depth
+	TailCallOptimization bool             // reuse the frame of a lambda for the calls in tail position of its body
+	Clock                func() time.Time // used by (time)
+	Rand                 *rand.Rand       // used by (rand)
+	ParallelWorkers      int              // goroutines evaluating arguments of pure functions in parallel, 0 disables it
+	Debugger             Debugger         // hooks of Step, not copied by Fork
+}
+
+func defaultOptions() Options {
+	return Options{
+		MaxStackDepth:        1000,
+		TailCallOptimization: true,
+		Clock:                time.Now,
+		Rand:                 rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Option : functional option applied after builtin modules are loaded
+type Option func(r *Runtime)
+
+func (r *Runtime) apply(opts ...Option) *Runtime {
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func WithMaxStackDepth(depth int) Option {
+	return func(r *Runtime) {
+		r.Options.MaxStackDepth = depth
+	}
+}
+
+func WithTailCallOptimization(enabled bool) Option {
+	return func(r *Runtime) {
+		r.Options.TailCallOptimization = enabled
+	}
+}
+
+// WithParallelEvaluation : evaluate independent arguments of pure functions on up to workers forks at the same time
+func WithParallelEvaluation(workers int) Option {
+	return func(r *Runtime) {
+		r.Options.ParallelWorkers = workers
+	}
+}
+
+func WithDebugger(d Debugger) Option {
+	return func(r *Runtime) {
+		r.Options.Debugger = d
+	}
+}
+
+func WithClock(clock func() time.Time) Option {
+	return func(r *Runtime) {
+		r.Options.Clock = clock
+	}
+}
+
+func WithRand(rnd *rand.Rand) Option {
+	return func(r *Runtime) {
+		r.Options.Rand = rnd
+	}
+}
+
+func WithStdout(w io.Writer) Option {
+	return func(r *Runtime) {
+		r.Stdout = w
+	}
+}
+
+func WithStderr(w io.Writer) Option {
+	return func(r *Runtime) {
+		r.Stderr = w
+	}
+}
+
+func WithStdin(reader io.Reader) Option {
+	return func(r *Runtime) {
+		r.Stdin = reader
+	}
+}
+
+// WithModules : load extra modules, replacing builtins of the same name
+func WithModules(modules ...Module) Option {
+	return func(r *Runtime) {
+		for _, m := range modules {
+			r.LoadModule(m)
+		}
+	}
+}
+
+// WithExtensions : load extra extensions, replacing builtins of the same name
+func WithExtensions(extensions ...Extension) Option {
+	return func(r *Runtime) {
+		for _, e := range extensions {
+			r.LoadExtension(e)
+		}
+	}
+}
+
+// WithoutModules : unload builtin modules or extensions by name
+func WithoutModules(names ...String) Option {
+	return func(r *Runtime) {
+		for _, name := range names {
+			delete(r.writableFrame(0), name)
+		}
+	}
+}
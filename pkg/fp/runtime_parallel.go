@@ -0,0 +1,278 @@
+package fp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// workerPool : one token per goroutine evaluating an argument, shared by r and its forks, see WithParallelEvaluation
+func (r *Runtime) workerPool() chan struct{} {
+	if cap(r.workers) != r.Options.ParallelWorkers {
+		r.workers = make(chan struct{}, max(r.Options.ParallelWorkers, 0))
+	}
+	return r.workers
+}
+
+// acquireWorker : take a token of the worker pool without waiting
+func (r *Runtime) acquireWorker() (chan struct{}, bool) {
+	workers := r.workerPool()
+	select {
+	case workers <- struct{}{}:
+		return workers, true
+	default:
+		return nil, false
+	}
+}
+
+// argument : argument of a pure function evaluated by stepPureArgs
+type argument struct {
+	expr     Expr
+	effects  effects
+	bind     String  // (let bind value) is evaluated on a fork, then bind is set on r in order
+	value    Expr    // value of bind
+	forkable bool    // the argument, or the value of bind, can be evaluated on a fork and is worth it
+	task     *future // set if evaluated on a fork
+}
+
+// analyzeArgs : effects of every argument, in order since let may hide the functions called by the next arguments
+func (r *Runtime) analyzeArgs(exprList []Expr) []argument {
+	a := newAnalyzer(r)
+	args := make([]argument, len(exprList))
+	for i, expr := range exprList {
+		arg := argument{expr: expr}
+		if name, value, ok := a.letBinding(expr); ok {
+			arg.bind, arg.value = name, value
+			arg.effects = a.expr(value)
+			arg.forkable = worthForking(arg.effects)
+			arg.effects.read("let")
+			arg.effects.write(name)
+			a.locals[name] = true
+		} else {
+			arg.effects = a.expr(expr)
+			arg.forkable = worthForking(arg.effects)
+		}
+		args[i] = arg
+	}
+	return args
+}
+
+// worthForking : the expression can run on a fork, and calls a lambda
+//
+// names, literals and calls of builtins only are cheaper than a fork and a goroutine, they are evaluated in order
+func worthForking(e effects) bool {
+	return e.purity != Effectful && len(e.writes) == 0 && e.calls
+}
+
+// letBinding : (let name value) calling the builtin let
+func (a *analyzer) letBinding(expr Expr) (String, Expr, bool) {
+	e, ok := expr.(LambdaExpr)
+	if !ok || len(e.Args) != 2 {
+		return "", nil, false
+	}
+	name, ok := a.r.bindable(e.Args[0])
+	if !ok {
+		// the let fails, it is done on r in order
+		return "", nil, false
+	}
+	o, _ := a.lookup(String(e.Name))
+	if m, ok := o.(Module); !ok || m.Name != "let" {
+		return "", nil, false
+	}
+	return name, e.Args[1], true
+}
+
+// inOrder : the argument changes r, so it is done on r after the previous arguments
+func (arg argument) inOrder() bool {
+	return !arg.forkable || arg.bind != ""
+}
+
+// stepPureArgs : stepArgs for a pure function, pure may be nil if the function is known to be pure
+//
+// when parallel evaluation is enabled, arguments that only read variables, and values of (let x value),
+// are evaluated on forks of r as soon as the arguments binding the variables they read are done.
+// other arguments and bindings are done on r in order, so the result, the bindings and the first error
+// are the same as with stepArgs. the last argument is always evaluated on r so that tail calls are kept
+func (r *Runtime) stepPureArgs(ctx context.Context, pure func() bool, exprList ...Expr) ([]Object, error) {
+	if workers := r.workerPool(); len(exprList) < 2 || len(workers) == cap(workers) || (pure != nil && !pure()) {
+		return r.stepArgs(ctx, exprList...)
+	}
+	args := r.analyzeArgs(exprList)
+	last := len(args) - 1
+
+	// forks are cancelled once the result is known, including when another argument fails
+	forkCtx, cancel := context.WithCancel(setOptionsToContext(ctx, &stepOptions{}))
+	defer cancel()
+	// fork every argument after done whose dependencies are done
+	fork := func(done int) {
+		for j := done + 1; j < last; j++ {
+			if args[j].task != nil || !args[j].forkable {
+				continue
+			}
+			ready := true
+			for i := done + 1; i < j; i++ {
+				if args[i].inOrder() && args[j].effects.dependsOn(args[i].effects) {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+			workers, ok := r.acquireWorker()
+			if !ok {
+				return
+			}
+			expr := args[j].expr
+			if args[j].bind != "" {
+				expr = args[j].value
+			}
+			child := r.Fork()
+			task := &future{done: make(chan struct{})}
+			args[j].task = task
+			go func() {
+				defer func() { <-workers }()
+				defer close(task.done)
+				task.value, task.err = child.Step(forkCtx, expr)
+			}()
+		}
+	}
+	// wait : first error of the forks before i
+	wait := func(i int) error {
+		for j := 0; j < i; j++ {
+			task := args[j].task
+			if task == nil {
+				continue
+			}
+			select {
+			case <-task.done:
+				if task.err != nil {
+					return task.err
+				}
+			case <-ctx.Done():
+				return contextError(ctx)
+			}
+		}
+		return nil
+	}
+
+	fork(-1)
+	outputs := make([]Object, len(args))
+	for i, arg := range args {
+		if arg.task != nil {
+			if arg.bind != "" {
+				// bind only if every argument before and the value succeed
+				if err := wait(i + 1); err != nil {
+					return nil, err
+				}
+				r.writableFrame(len(r.Stack) - 1)[arg.bind] = arg.task.value
+				outputs[i] = arg.task.value
+				fork(i)
+			}
+			continue
+		}
+		if arg.inOrder() {
+			// effects happen only if every argument before succeeds
+			if err := wait(i); err != nil {
+				return nil, err
+			}
+		}
+		stepCtx := forkCtx
+		if i == last {
+			// a tail call only if the call of the arguments is one
+			stepCtx = ctx
+		}
+		v, err := r.Step(stepCtx, arg.expr)
+		if err != nil {
+			if waitErr := wait(i); waitErr != nil {
+				return nil, waitErr
+			}
+			return nil, err
+		}
+		outputs[i] = v
+		if arg.inOrder() {
+			fork(i)
+		}
+	}
+	if err := wait(len(args)); err != nil {
+		return nil, err
+	}
+	for i, arg := range args {
+		if arg.task != nil {
+			outputs[i] = arg.task.value
+		}
+	}
+	return r.unwrapArgs(ctx, outputs)
+}
+
+// parallelMap : apply f to every element of l, on forks of r when parallel evaluation is enabled and f is not effectful
+//
+// the first error is the one of the first failing element, as if elements were applied in order
+func (r *Runtime) parallelMap(ctx context.Context, f Object, l List) (List, error) {
+	outputs := make(List, len(l))
+	if workers := r.workerPool(); len(l) < 2 || len(workers) == cap(workers) || r.PurityOf(f) == Effectful {
+		for i, v := range l {
+			o, err := r.Apply(ctx, f, v)
+			if err != nil {
+				return nil, err
+			}
+			outputs[i] = o
+		}
+		return outputs, nil
+	}
+	ctx = setOptionsToContext(ctx, &stepOptions{})
+	errs := make([]error, len(l))
+	var next atomic.Int64
+	var failed atomic.Int64 // elements after the first failing one are not needed
+	failed.Store(int64(len(l)))
+	apply := func(r *Runtime) {
+		for {
+			i := int(next.Add(1) - 1)
+			if i >= len(l) || int64(i) > failed.Load() {
+				return
+			}
+			o, err := func() (o Object, err error) {
+				defer func() {
+					if p := recover(); p != nil {
+						o, err = nil, &RuntimeError{Err: fmt.Errorf("runtime error: panic: %v", p)}
+					}
+				}()
+				return r.Apply(ctx, f, l[i])
+			}()
+			if err != nil {
+				errs[i] = err
+				for {
+					cur := failed.Load()
+					if int64(i) >= cur || failed.CompareAndSwap(cur, int64(i)) {
+						break
+					}
+				}
+				continue
+			}
+			outputs[i] = o
+		}
+	}
+	wg := &sync.WaitGroup{}
+	for k := 1; k < len(l); k++ {
+		workers, ok := r.acquireWorker()
+		if !ok {
+			break
+		}
+		child := r.Fork()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+			apply(child)
+		}()
+	}
+	apply(r)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return outputs, nil
+}
@@ -0,0 +1,56 @@
+package fp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+const fibSource = `(let fib (lambda n (case (sign (sub n 1)) 1 (add (fib (sub n 1)) (fib (sub n 2))) _ n)))`
+
+// BenchmarkFib : (fib 16) evaluated in order and with parallel evaluation, compare with -cpu 1,2,4,8
+func BenchmarkFib(b *testing.B) {
+	exprs, err := ParseSource(fibSource + ` (fib 16)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 2, 4, 8} {
+		name := "sequential"
+		if workers > 0 {
+			name = fmt.Sprintf("workers=%d", workers)
+		}
+		b.Run(name, func(b *testing.B) {
+			r := NewStdRuntime(WithParallelEvaluation(workers))
+			if _, err := r.Step(context.Background(), exprs[0]); err != nil {
+				b.Fatal(err)
+			}
+			for b.Loop() {
+				o, err := r.Step(context.Background(), exprs[1])
+				if err != nil || o != Int(987) {
+					b.Fatalf("(fib 16) = %v, %v", o, err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParallelMap : map of an expensive pure lambda over a list
+func BenchmarkParallelMap(b *testing.B) {
+	exprs, err := ParseSource(fibSource + ` (map (list 12 12 12 12 12 12 12 12) fib)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, workers := range []int{0, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			r := NewStdRuntime(WithParallelEvaluation(workers))
+			if _, err := r.Step(context.Background(), exprs[0]); err != nil {
+				b.Fatal(err)
+			}
+			for b.Loop() {
+				if _, err := r.Step(context.Background(), exprs[1]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
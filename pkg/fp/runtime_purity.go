@@ -0,0 +1,402 @@
+package fp
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Purity : what calling a function may do, see Runtime.PurityOf
+type Purity int
+
+const (
+	Pure       Purity = iota // the result only depends on the arguments
+	ReadsOuter               // reads variables bound outside of the function, the result may change when they are rebound
+	Effectful                // prints, reads input, rebinds outer variables, calls unknown functions...
+)
+
+func (p Purity) String() string {
+	switch p {
+	case Pure:
+		return "pure"
+	case ReadsOuter:
+		return "reads-outer"
+	default:
+		return "effectful"
+	}
+}
+
+// effects : what evaluating an expression may do, see analyzer
+type effects struct {
+	purity Purity
+	reads  map[String]struct{} // names that may be looked up
+	writes map[String]struct{} // names that may be bound or deleted in the current frame by let and del
+	calls  bool                // calls a lambda, see argument.forkable
+}
+
+func (e *effects) read(name String) {
+	if e.reads == nil {
+		e.reads = make(map[String]struct{})
+	}
+	e.reads[name] = struct{}{}
+}
+
+func (e *effects) write(name String) {
+	if e.writes == nil {
+		e.writes = make(map[String]struct{})
+	}
+	e.writes[name] = struct{}{}
+}
+
+// at : purity is at least p
+func (e *effects) at(p Purity) {
+	e.purity = max(e.purity, p)
+}
+
+// readAll : every name in expr, for expressions that are not evaluated yet like lambda bodies
+func (e *effects) readAll(expr Expr) {
+	switch expr := expr.(type) {
+	case NameExpr:
+		e.read(String(expr))
+	case LambdaExpr:
+		e.read(String(expr.Name))
+		for _, arg := range expr.Args {
+			e.readAll(arg)
+		}
+	}
+}
+
+func (e *effects) merge(other effects) {
+	e.at(other.purity)
+	e.calls = e.calls || other.calls
+	for name := range other.reads {
+		e.read(name)
+	}
+	for name := range other.writes {
+		e.write(name)
+	}
+}
+
+// dependsOn : e must be evaluated after other
+func (e effects) dependsOn(other effects) bool {
+	if other.purity == Effectful {
+		return true
+	}
+	for name := range other.writes {
+		if _, ok := e.reads[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzer : conservative static analysis of expressions
+//
+// names are resolved on the stack of the runtime at the time of the analysis,
+// calls to names bound during the evaluation (parameters, let, with) are effectful
+type analyzer struct {
+	r        *Runtime
+	lambda   bool            // analyzing the body of a lambda, let and del on outer variables are effectful
+	frame    Frame           // frame captured by the lambda, searched before the stack
+	locals   map[String]bool // names bound during the evaluation
+	visiting map[lambdaKey]bool
+	resolved *[]resolution // names looked up by the analysis, if it is cached
+}
+
+// lambdaKey : lambdas being analyzed are assumed pure so that the analysis of recursive functions terminates
+type lambdaKey struct {
+	name  String
+	frame uintptr // lambdas are told apart by their captured frame, created by each (lambda ...)
+}
+
+func newAnalyzer(r *Runtime) *analyzer {
+	return &analyzer{
+		r:        r,
+		locals:   make(map[String]bool),
+		visiting: make(map[lambdaKey]bool),
+	}
+}
+
+// lookup : value of a name bound before the evaluation
+func (a *analyzer) lookup(name String) (Object, bool) {
+	if a.locals[name] {
+		return nil, false
+	}
+	o, ok := a.r.resolve(a.frame, name)
+	if a.resolved != nil {
+		*a.resolved = append(*a.resolved, resolution{frame: a.frame, name: name, value: o, bound: ok})
+	}
+	return o, ok
+}
+
+// resolve : name in frame, then on the stack
+func (r *Runtime) resolve(frame Frame, name String) (Object, bool) {
+	if o, ok := frame[name]; ok {
+		return o, true
+	}
+	for i := len(r.Stack) - 1; i >= 0; i-- {
+		if o, ok := r.Stack[i][name]; ok {
+			return o, true
+		}
+	}
+	return nil, false
+}
+
+// outer : the name is looked up outside of the locals and the captured frame
+func (a *analyzer) outer(name String) bool {
+	if a.locals[name] {
+		return false
+	}
+	_, ok := a.frame[name]
+	return !ok
+}
+
+func (a *analyzer) expr(expr Expr) effects {
+	var e effects
+	switch expr := expr.(type) {
+	case NameExpr:
+		if _, err := a.r.parseLiteral(String(expr)); err == nil {
+			break
+		}
+		name := String(expr)
+		e.read(name)
+		if a.outer(name) {
+			switch o, _ := a.lookup(name); o.(type) {
+			case Module, Lambda, Memo, Macro:
+				// functions are not variables of the program
+			default:
+				e.at(ReadsOuter)
+			}
+		}
+	case LambdaExpr:
+		a.call(expr, &e)
+	default:
+		e.at(Effectful)
+	}
+	return e
+}
+
+func (a *analyzer) args(args []Expr, e *effects) {
+	for _, arg := range args {
+		e.merge(a.expr(arg))
+	}
+}
+
+func (a *analyzer) call(expr LambdaExpr, e *effects) {
+	name := String(expr.Name)
+	e.read(name)
+	o, ok := a.lookup(name)
+	if !ok {
+		e.at(Effectful)
+		return
+	}
+	switch o := o.(type) {
+	case Lambda:
+		e.merge(a.lambdaEffects(name, o))
+		e.calls = true
+		a.args(expr.Args, e)
+	case Memo:
+		e.merge(a.lambdaEffects(name, o.Lambda))
+		e.calls = true
+		a.args(expr.Args, e)
+	case Module:
+		switch {
+		case o.Pure:
+			a.args(expr.Args, e)
+		case o.Name == "quote":
+		case o.Name == "lambda":
+			e.readAll(expr)
+		case o.Name == "let" || o.Name == "del":
+			if len(expr.Args) == 0 {
+				e.at(Effectful)
+				return
+			}
+			target, ok := a.r.bindable(expr.Args[0])
+			if !ok {
+				e.at(Effectful)
+				return
+			}
+			if _, bound := a.lookup(target); a.lambda && bound {
+				// the frame of the call starts with the captured frame, rebinding outer variables is an effect
+				e.at(Effectful)
+			}
+			e.write(target)
+			a.locals[target] = true
+			a.args(expr.Args[1:], e)
+		case o.Name == "with" || o.Name == "letrec":
+			bindings, body, err := a.r.parseBindings(expr)
+			if err != nil {
+				e.at(Effectful)
+				return
+			}
+			for _, b := range bindings {
+				a.locals[b.name] = true
+			}
+			for _, b := range bindings {
+				e.merge(a.expr(b.expr))
+			}
+			e.merge(a.expr(body))
+		default:
+			e.at(Effectful)
+		}
+	default:
+		e.at(Effectful)
+	}
+}
+
+// lambdaEffects : effects of calling f, bindings made by f are in its own frame
+func (a *analyzer) lambdaEffects(name String, f Lambda) effects {
+	key := lambdaKey{name: name}
+	if f.Frame != nil {
+		key = lambdaKey{frame: reflect.ValueOf(f.Frame).Pointer()}
+	}
+	if a.visiting[key] {
+		return effects{}
+	}
+	a.visiting[key] = true
+	defer delete(a.visiting, key)
+	inner := &analyzer{
+		r:        a.r,
+		lambda:   true,
+		frame:    f.Frame,
+		locals:   make(map[String]bool),
+		visiting: a.visiting,
+		resolved: a.resolved,
+	}
+	for _, param := range f.Params {
+		inner.locals[param] = true
+	}
+	if f.Rest != "" {
+		inner.locals[f.Rest] = true
+	}
+	var e effects
+	for _, d := range f.Defaults {
+		e.merge(inner.expr(d))
+	}
+	e.merge(inner.expr(f.Impl))
+	e.writes = nil
+	return e
+}
+
+// resolution : a name looked up by the analysis of a lambda and what it was bound to
+type resolution struct {
+	frame Frame // captured frame searched before the stack
+	name  String
+	value Object
+	bound bool
+}
+
+// purityCache : purity of a lambda, valid while the names looked up by its analysis resolve to the same functions
+type purityCache struct {
+	mtx      sync.Mutex
+	valid    bool
+	purity   Purity
+	resolved []resolution
+}
+
+// sameBinding : the analysis gives the same result with o as with other
+func sameBinding(o Object, other Object) bool {
+	switch o := o.(type) {
+	case Lambda:
+		other, ok := other.(Lambda)
+		return ok && sameLambda(o, other)
+	case Memo:
+		other, ok := other.(Memo)
+		return ok && sameLambda(o.Lambda, other.Lambda)
+	case Module:
+		other, ok := other.(Module)
+		return ok && o.Name == other.Name && o.Pure == other.Pure
+	case Macro:
+		_, ok := other.(Macro)
+		return ok
+	default:
+		// variables are only read
+		switch other.(type) {
+		case Lambda, Memo, Module, Macro:
+			return false
+		default:
+			return true
+		}
+	}
+}
+
+// sameLambda : lambdas are told apart by their captured frame, like in lambdaKey
+func sameLambda(l Lambda, other Lambda) bool {
+	return l.Frame != nil && reflect.ValueOf(l.Frame).Pointer() == reflect.ValueOf(other.Frame).Pointer()
+}
+
+// lambdaPurity : purity of calling f with the current stack, analyzed once per lambda
+//
+// the result is cached on f and reused as long as every name its analysis looked up,
+// in captured frames or on the stack, is still bound to the same function or to a variable
+func (r *Runtime) lambdaPurity(name String, f Lambda) Purity {
+	c := f.purity
+	if c == nil || f.Frame == nil {
+		return newAnalyzer(r).lambdaEffects(name, f).purity
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.valid && r.stillResolves(c.resolved) {
+		return c.purity
+	}
+	a := newAnalyzer(r)
+	a.resolved = &[]resolution{}
+	c.purity = a.lambdaEffects(name, f).purity
+	c.resolved, c.valid = *a.resolved, true
+	return c.purity
+}
+
+// stillResolves : every name is bound as it was during the analysis
+func (r *Runtime) stillResolves(resolved []resolution) bool {
+	for _, res := range resolved {
+		if o, ok := r.resolve(res.frame, res.name); ok != res.bound || !sameBinding(res.value, o) {
+			return false
+		}
+	}
+	return true
+}
+
+// PurityOf : static analysis of calling f with the current stack
+//
+// builtins are pure if they are marked as Pure, lambdas are analyzed with the functions they call,
+// calls to unknown functions, like parameters, are effectful
+func (r *Runtime) PurityOf(f Object) Purity {
+	switch f := f.(type) {
+	case Lambda:
+		return r.lambdaPurity("", f)
+	case Memo:
+		return r.lambdaPurity("", f.Lambda)
+	case Module:
+		if f.Pure {
+			return Pure
+		}
+		return Effectful
+	default:
+		return Effectful
+	}
+}
+
+var pureExtension = Extension{
+	Name: "pure?",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("pure?", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		if mustGetExtensionContext(ctx).Runtime().PurityOf(values[0]) == Pure {
+			return Int(1), nil
+		}
+		return Int(0), nil
+	},
+	Man: "module: (pure? f) - 1 if calling f only depends on its arguments, 0 otherwise",
+}
+
+var purityExtension = Extension{
+	Name: "purity",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("purity", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		return String(mustGetExtensionContext(ctx).Runtime().PurityOf(values[0]).String()), nil
+	},
+	Man: "module: (purity f) - \"pure\", \"reads-outer\" or \"effectful\"",
+}
@@ -0,0 +1,30 @@
+package fp
+
+import "testing"
+
+func TestPurityCache(t *testing.T) {
+	// f is defined before g, so g is looked up on the stack when f is called
+	const defs = `(let f (lambda x (g x))) (let g (lambda x (add x 1))) (let before (purity f)) `
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(list before (purity f))`, "[pure,pure,]"},
+		{`(let g (lambda x (print x))) (list before (purity f))`, "[pure,effectful,]"},
+		{`(let g (lambda x (print x))) (let g (lambda x (sub x 1))) (list before (purity f))`, "[pure,pure,]"},
+		{`(let g 1) (list before (purity f))`, "[pure,effectful,]"},
+		{`(del g) (list before (purity f))`, "[pure,effectful,]"},
+		{`(let h (lambda g (purity f))) (list before (h 1) (purity f))`, "[pure,effectful,pure,]"},
+		{`(with (g (lambda x (print x))) (list before (purity f)))`, "[pure,effectful,]"},
+	}
+	for _, test := range tests {
+		o, err := run(t, defs+test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+}
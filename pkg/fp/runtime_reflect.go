@@ -0,0 +1,406 @@
+package fp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	objectType  = reflect.TypeOf((*Object)(nil)).Elem()
+)
+
+// RegisterFunc : load a Go function as an extension, arguments and results are converted using its signature
+//
+// supported types are integers, float64, bool, string, slices, maps, funcs and Object,
+// a first parameter of type context.Context receives the ExtensionContext,
+// a last result of type error is returned as a runtime error
+func (r *Runtime) RegisterFunc(name string, fn any, doc string) error {
+	e, err := makeExtensionFromFunc(String(name), reflect.ValueOf(fn), doc)
+	if err != nil {
+		return err
+	}
+	r.LoadExtension(e)
+	return nil
+}
+
+func makeExtensionFromFunc(name String, fn reflect.Value, doc string) (Extension, error) {
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return Extension{}, fmt.Errorf("%s: expected a function, got %s", name, fn.Kind())
+	}
+	t := fn.Type()
+	withContext := t.NumIn() > 0 && t.In(0) == contextType
+	if doc == "" {
+		doc = fmt.Sprintf("module: (%s ...) - go function %s", name, t)
+	}
+	return Extension{
+		Name: name,
+		Exec: func(ctx context.Context, values ...Object) (o Object, err error) {
+			ec := mustGetExtensionContext(ctx)
+			var in []reflect.Value
+			if withContext {
+				in = append(in, reflect.ValueOf(ec))
+			}
+			first := len(in)
+			numParams := t.NumIn() - first
+			if t.IsVariadic() {
+				if len(values) < numParams-1 {
+					return nil, fmt.Errorf("%s requires at least %d arguments, got %d", name, numParams-1, len(values))
+				}
+			} else if len(values) != numParams {
+				return nil, fmt.Errorf("%s requires %d arguments, got %d", name, numParams, len(values))
+			}
+			for i, v := range values {
+				var pt reflect.Type
+				if t.IsVariadic() && first+i >= t.NumIn()-1 {
+					pt = t.In(t.NumIn() - 1).Elem()
+				} else {
+					pt = t.In(first + i)
+				}
+				arg, err := fromObject(ec, v, pt)
+				if err != nil {
+					return nil, fmt.Errorf("%s: argument %d: %w", name, i+1, err)
+				}
+				in = append(in, arg)
+			}
+			// lambdas converted into go funcs report failures by panicking with applyError
+			defer func() {
+				if p := recover(); p != nil {
+					applyErr, ok := p.(applyError)
+					if !ok {
+						panic(p)
+					}
+					o, err = nil, fmt.Errorf("%s: %w", name, applyErr.err)
+				}
+			}()
+			return fromResults(ec, name, t, fn.Call(in))
+		},
+		Man: doc,
+	}, nil
+}
+
+// applyError : error of a lambda called from a go func without error result
+type applyError struct {
+	err error
+}
+
+func fromResults(ec ExtensionContext, name String, t reflect.Type, out []reflect.Value) (Object, error) {
+	if t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, err
+		}
+		out = out[:len(out)-1]
+	}
+	var outputs List
+	for i, v := range out {
+		o, err := toObject(ec, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: result %d: %w", name, i+1, err)
+		}
+		outputs = append(outputs, o)
+	}
+	switch len(outputs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return outputs[0], nil
+	default:
+		return outputs, nil
+	}
+}
+
+// typeName : name of the fp type converted into go type t
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		return "Int"
+	case reflect.String:
+		return "String"
+	case reflect.Slice, reflect.Array:
+		return "List"
+	case reflect.Map, reflect.Struct:
+		return "Dict"
+	case reflect.Pointer:
+		return typeName(t.Elem())
+	case reflect.Func:
+		return "Lambda"
+	default:
+		return t.String()
+	}
+}
+
+func typeError(o Object, t reflect.Type) error {
+	return fmt.Errorf("expected %s, got %s", typeName(t), getType(o))
+}
+
+// fromObject : convert an fp object into a go value of type t, ec is used to call lambdas converted into funcs
+func fromObject(ec ExtensionContext, o Object, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		return fromObjectToAny(ec, o, t)
+	}
+	if t == objectType || (t.Kind() == reflect.Interface && reflect.TypeOf(o) != nil && reflect.TypeOf(o).Implements(t)) {
+		if o == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(o).Convert(t), nil
+	}
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := o.(Int)
+		if !ok {
+			return v, typeError(o, t)
+		}
+		if v.OverflowInt(int64(i)) {
+			return v, fmt.Errorf("%d overflows %s", i, t)
+		}
+		v.SetInt(int64(i))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := o.(Int)
+		if !ok {
+			return v, typeError(o, t)
+		}
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return v, fmt.Errorf("%d overflows %s", i, t)
+		}
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		i, ok := o.(Int)
+		if !ok {
+			return v, typeError(o, t)
+		}
+		v.SetFloat(float64(i))
+	case reflect.Bool:
+		i, ok := o.(Int)
+		if !ok {
+			return v, typeError(o, t)
+		}
+		v.SetBool(i != 0)
+	case reflect.String:
+		s, ok := o.(String)
+		if !ok {
+			return v, typeError(o, t)
+		}
+		v.SetString(string(s))
+	case reflect.Slice:
+		l, ok := o.(List)
+		if !ok {
+			return v, typeError(o, t)
+		}
+		v.Set(reflect.MakeSlice(t, len(l), len(l)))
+		for i, elem := range l {
+			e, err := fromObject(ec, elem, t.Elem())
+			if err != nil {
+				return v, fmt.Errorf("element %d: %w", i+1, err)
+			}
+			v.Index(i).Set(e)
+		}
+	case reflect.Map:
+		d, ok := o.(Dict)
+		if !ok {
+			return v, typeError(o, t)
+		}
+		v.Set(reflect.MakeMapWithSize(t, len(d)))
+		for key, elem := range d {
+			k, err := fromObject(ec, key, t.Key())
+			if err != nil {
+				return v, fmt.Errorf("key %s: %w", key, err)
+			}
+			e, err := fromObject(ec, elem, t.Elem())
+			if err != nil {
+				return v, fmt.Errorf("value of %s: %w", key, err)
+			}
+			v.SetMapIndex(k, e)
+		}
+	case reflect.Struct:
+		d, ok := o.(Dict)
+		if !ok {
+			return v, typeError(o, t)
+		}
+		if err := dictToStruct(ec, d, v); err != nil {
+			return v, err
+		}
+	case reflect.Pointer:
+		if o == nil {
+			return v, nil
+		}
+		e, err := fromObject(ec, o, t.Elem())
+		if err != nil {
+			return v, err
+		}
+		v.Set(reflect.New(t.Elem()))
+		v.Elem().Set(e)
+	case reflect.Func:
+		switch o.(type) {
+		case Lambda, Memo, Module:
+		default:
+			return v, typeError(o, t)
+		}
+		if ec == nil {
+			return v, fmt.Errorf("cannot convert %s into %s without runtime", getType(o), t)
+		}
+		v.Set(makeFuncFromObject(ec, o, t))
+	default:
+		return v, fmt.Errorf("unsupported go type %s", t)
+	}
+	return v, nil
+}
+
+// makeFuncFromObject : go func of type t calling lambda or module f
+func makeFuncFromObject(ec ExtensionContext, f Object, t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		fail := func(err error) []reflect.Value {
+			if t.NumOut() == 0 || t.Out(t.NumOut()-1) != errorType {
+				panic(applyError{err: err})
+			}
+			out := make([]reflect.Value, t.NumOut())
+			for i := range out {
+				out[i] = reflect.Zero(t.Out(i))
+			}
+			out[len(out)-1] = reflect.ValueOf(&err).Elem()
+			return out
+		}
+		var args []Object
+		for i, v := range in {
+			if t.IsVariadic() && i == len(in)-1 {
+				for j := 0; j < v.Len(); j++ {
+					arg, err := toObject(ec, v.Index(j))
+					if err != nil {
+						return fail(err)
+					}
+					args = append(args, arg)
+				}
+				break
+			}
+			arg, err := toObject(ec, v)
+			if err != nil {
+				return fail(err)
+			}
+			args = append(args, arg)
+		}
+		o, err := ec.Apply(f, args...)
+		if err != nil {
+			return fail(err)
+		}
+		numOut := t.NumOut()
+		if numOut > 0 && t.Out(numOut-1) == errorType {
+			numOut--
+		}
+		out := make([]reflect.Value, 0, t.NumOut())
+		switch numOut {
+		case 0:
+		case 1:
+			v, err := fromObject(ec, o, t.Out(0))
+			if err != nil {
+				return fail(err)
+			}
+			out = append(out, v)
+		default:
+			l, ok := o.(List)
+			if !ok || len(l) != numOut {
+				return fail(fmt.Errorf("expected a list of %d results, got %s", numOut, o))
+			}
+			for i, elem := range l {
+				v, err := fromObject(ec, elem, t.Out(i))
+				if err != nil {
+					return fail(err)
+				}
+				out = append(out, v)
+			}
+		}
+		if numOut < t.NumOut() {
+			out = append(out, reflect.Zero(errorType))
+		}
+		return out
+	})
+}
+
+// toObject : convert a go value into an fp object, funcs are converted into modules only if ec is not nil
+func toObject(ec ExtensionContext, v reflect.Value) (Object, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Type().Implements(objectType) && !(v.Kind() == reflect.Interface && v.IsNil()) {
+		return v.Interface().(Object), nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt {
+			return nil, fmt.Errorf("%d overflows Int", v.Uint())
+		}
+		return Int(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || f > math.MaxInt || f < math.MinInt {
+			return nil, fmt.Errorf("%v cannot be represented as Int", f)
+		}
+		return Int(f), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return Int(1), nil
+		}
+		return Int(0), nil
+	case reflect.String:
+		return String(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return List(nil), nil
+		}
+		l := make(List, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			o, err := toObject(ec, v.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i+1, err)
+			}
+			l = append(l, o)
+		}
+		return l, nil
+	case reflect.Map:
+		d := make(Dict, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k, err := toObject(ec, iter.Key())
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %w", iter.Key(), err)
+			}
+			if k == nil || !reflect.TypeOf(k).Comparable() {
+				return nil, fmt.Errorf("key %v: %s cannot be a dict key", iter.Key(), getType(k))
+			}
+			e, err := toObject(ec, iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("value of %v: %w", iter.Key(), err)
+			}
+			d[k] = e
+		}
+		return d, nil
+	case reflect.Struct:
+		return structToDict(ec, v)
+	case reflect.Func:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if ec == nil {
+			return nil, fmt.Errorf("cannot convert %s into Object without runtime", v.Type())
+		}
+		e, err := makeExtensionFromFunc("func", v, "")
+		if err != nil {
+			return nil, err
+		}
+		return makeModuleFromExtension(e), nil
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return toObject(ec, v.Elem())
+	default:
+		return nil, errors.New("unsupported go type " + v.Type().String())
+	}
+}
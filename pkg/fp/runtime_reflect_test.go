@@ -0,0 +1,97 @@
+package fp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterFunc(t *testing.T) {
+	r := NewStdRuntime()
+	funcs := map[string]any{
+		"go-add":   func(a, b int) int { return a + b },
+		"go-upper": strings.ToUpper,
+		"go-sum": func(xs ...int64) (s int64) {
+			for _, x := range xs {
+				s += x
+			}
+			return s
+		},
+		"go-keys":  func(m map[string]int) int { return len(m) },
+		"go-map":   func(v any) map[string]any { return map[string]any{"a": v, "b": 2} },
+		"go-split": func(s string) []string { return strings.Split(s, ",") },
+		"go-div": func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, errors.New("go-div by zero")
+			}
+			return a / b, nil
+		},
+		"go-pair":   func(a int) (int, string) { return a, "x" },
+		"go-not":    func(b bool) bool { return !b },
+		"go-byte":   func(b uint8) uint8 { return b },
+		"go-apply":  func(f func(int) int, x int) int { return f(x) },
+		"go-ctx":    func(ctx context.Context, x int) int { _ = mustGetExtensionContext(ctx); return x },
+		"go-object": func(o Object) String { return getType(o) },
+		"go-half":   func(x int) float64 { return float64(x) / 2 },
+	}
+	for name, fn := range funcs {
+		if err := r.RegisterFunc(name, fn, ""); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	tests := []struct {
+		source string
+		want   string
+		err    string
+	}{
+		{source: `(go-add 1 2)`, want: "3"},
+		{source: `(go-upper "abc")`, want: "ABC"},
+		{source: `(go-sum)`, want: "0"},
+		{source: `(go-sum 1 2 3)`, want: "6"},
+		{source: `(go-sum * (list 1 2))`, want: "3"},
+		{source: `(go-keys (go-map 1))`, want: "2"},
+		{source: `(go-split "a,b")`, want: "[a,b,]"},
+		{source: `(go-div 6 3)`, want: "2"},
+		{source: `(go-pair 1)`, want: "[1,x,]"},
+		{source: `(go-not 0)`, want: "1"},
+		{source: `(go-apply (lambda x (mul x 10)) 4)`, want: "40"},
+		{source: `(go-ctx 5)`, want: "5"},
+		{source: `(go-object (list))`, want: "List"},
+		{source: `(go-half 4)`, want: "2"},
+		// errors
+		{source: `(go-add 1)`, err: "go-add requires 2 arguments, got 1"},
+		{source: `(go-add 1 "a")`, err: "go-add: argument 2: expected Int, got String"},
+		{source: `(go-split (list))`, err: "go-split: argument 1: expected String, got List"},
+		{source: `(go-keys (go-map "x"))`, err: "go-keys: argument 1: value of a: expected Int, got String"},
+		{source: `(go-byte 256)`, err: "go-byte: argument 1: 256 overflows uint8"},
+		{source: `(go-byte -1)`, err: "go-byte: argument 1: -1 overflows uint8"},
+		{source: `(go-div 1 0)`, err: "go-div by zero"},
+		{source: `(go-half 3)`, err: "go-half: result 1: 1.5 cannot be represented as Int"},
+		{source: `(go-apply (lambda x (div x 0)) 4)`, err: "go-apply: "},
+		{source: `(go-apply 1 4)`, err: "go-apply: argument 1: expected Lambda, got Int"},
+	}
+	for _, test := range tests {
+		exprs, err := ParseSource(test.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o, err := r.Step(context.Background(), exprs[0])
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.source, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+	if err := r.RegisterFunc("bad", 1, ""); err == nil || err.Error() != "bad: expected a function, got int" {
+		t.Errorf("RegisterFunc with an int: got error %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package fp
+
+import (
+	"context"
+	"fmt"
+)
+
+// Result : (ok value) or (err value)
+type Result struct {
+	Ok    bool
+	Value Object
+}
+
+func (r Result) String() string {
+	if r.Ok {
+		return fmt.Sprintf("(ok %v)", r.Value)
+	}
+	return fmt.Sprintf("(err %v)", r.Value)
+}
+
+func (r Result) MustTypeObject() {}
+
+// Optional : (some value) or (none), reported as Option by (type)
+type Optional struct {
+	Some  bool
+	Value Object
+}
+
+func (o Optional) String() string {
+	if o.Some {
+		return fmt.Sprintf("(some %v)", o.Value)
+	}
+	return "(none)"
+}
+
+func (o Optional) MustTypeObject() {}
+
+// equal : structural equality, used by case
+func equal(a Object, b Object) bool {
+	switch a := a.(type) {
+	case Int, String, Wildcard, Unwrap:
+		return a == b
+	case List:
+		l, ok := b.(List)
+		if !ok || len(a) != len(l) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], l[i]) {
+				return false
+			}
+		}
+		return true
+	case Dict:
+		d, ok := b.(Dict)
+		if !ok || len(a) != len(d) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := d[k]; !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case Result:
+		r, ok := b.(Result)
+		return ok && a.Ok == r.Ok && equal(a.Value, r.Value)
+	case Optional:
+		o, ok := b.(Optional)
+		return ok && a.Some == o.Some && equal(a.Value, o.Value)
+	case Quote:
+		q, ok := b.(Quote)
+		return ok && a.String() == q.String()
+	case nil:
+		return b == nil
+	default:
+		return false
+	}
+}
+
+// match : like equal, but wildcards match anything, including inside (ok _) or (list 1 _)
+func match(pattern Object, value Object) bool {
+	switch p := pattern.(type) {
+	case Wildcard:
+		return true
+	case List:
+		l, ok := value.(List)
+		if !ok || len(p) != len(l) {
+			return false
+		}
+		for i := range p {
+			if !match(p[i], l[i]) {
+				return false
+			}
+		}
+		return true
+	case Result:
+		r, ok := value.(Result)
+		return ok && p.Ok == r.Ok && match(p.Value, r.Value)
+	case Optional:
+		o, ok := value.(Optional)
+		return ok && p.Some == o.Some && (!p.Some || match(p.Value, o.Value))
+	default:
+		return equal(pattern, value)
+	}
+}
+
+var okExtension = Extension{
+	Name: "ok",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("ok", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		return Result{Ok: true, Value: values[0]}, nil
+	},
+	Man:  "module: (ok 3) - make a successful result",
+	Pure: true,
+}
+
+var errExtension = Extension{
+	Name: "err",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("err", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		return Result{Ok: false, Value: values[0]}, nil
+	},
+	Man:  "module: (err \"not found\") - make a failed result",
+	Pure: true,
+}
+
+var someExtension = Extension{
+	Name: "some",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("some", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		return Optional{Some: true, Value: values[0]}, nil
+	},
+	Man:  "module: (some 3) - make an option with a value",
+	Pure: true,
+}
+
+var noneExtension = Extension{
+	Name: "none",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("none", len(values), 0, 0); err != nil {
+			return nil, err
+		}
+		return Optional{Some: false}, nil
+	},
+	Man:  "module: (none) - make an option without value",
+	Pure: true,
+}
+
+var unwrapExtension = Extension{
+	Name: "unwrap",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("unwrap", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		switch v := values[0].(type) {
+		case Result:
+			if !v.Ok {
+				// the error value can be caught by try
+				return nil, &RaisedError{Payload: v.Value}
+			}
+			return v.Value, nil
+		case Optional:
+			if !v.Some {
+				return nil, fmt.Errorf("unwrap: none")
+			}
+			return v.Value, nil
+		default:
+			return nil, fmt.Errorf("first argument must be result or option")
+		}
+	},
+	Man:  "module: (unwrap r) - get the value of (ok x) or (some x), raise the error of (err e)",
+	Pure: true,
+}
+
+var unwrapOrExtension = Extension{
+	Name: "unwrap-or",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("unwrap-or", len(values), 2, 2); err != nil {
+			return nil, err
+		}
+		switch v := values[0].(type) {
+		case Result:
+			if v.Ok {
+				return v.Value, nil
+			}
+		case Optional:
+			if v.Some {
+				return v.Value, nil
+			}
+		default:
+			return nil, fmt.Errorf("first argument must be result or option")
+		}
+		return values[1], nil
+	},
+	Man:  "module: (unwrap-or r 0) - get the value of (ok x) or (some x), otherwise the default value",
+	Pure: true,
+}
+
+var mapOkExtension = Extension{
+	Name: "map-ok",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("map-ok", len(values), 2, 2); err != nil {
+			return nil, err
+		}
+		ec := mustGetExtensionContext(ctx)
+		switch v := values[0].(type) {
+		case Result:
+			if !v.Ok {
+				return v, nil
+			}
+			o, err := ec.Apply(values[1], v.Value)
+			if err != nil {
+				return nil, err
+			}
+			return Result{Ok: true, Value: o}, nil
+		case Optional:
+			if !v.Some {
+				return v, nil
+			}
+			o, err := ec.Apply(values[1], v.Value)
+			if err != nil {
+				return nil, err
+			}
+			return Optional{Some: true, Value: o}, nil
+		default:
+			return nil, fmt.Errorf("first argument must be result or option")
+		}
+	},
+	Man: "module: (map-ok r (lambda x (add x 1))) - apply a function to the value of (ok x) or (some x), keep errors and none",
+}
+
+var tryCallExtension = Extension{
+	Name: "try-call",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("try-call", len(values), 1, -1); err != nil {
+			return nil, err
+		}
+		o, err := mustGetExtensionContext(ctx).Apply(values[0], values[1:]...)
+		if err != nil {
+			if !catchable(err) {
+				return nil, err
+			}
+			// the call is not a step, add it to the trace like Step does for the body of try
+			return Result{Ok: false, Value: errorToObject(withTrace(err, applyName(values[0])))}, nil
+		}
+		return Result{Ok: true, Value: o}, nil
+	},
+	Man: "module: (try-call div 1 0) - call a function, return (ok value) or (err e) where e is like in try",
+}
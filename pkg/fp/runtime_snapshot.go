@@ -0,0 +1,379 @@
+package fp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+const snapshotVersion = 1
+
+// snapshot : frames are stored once in a table and referenced by index,
+// so frames shared by the stack and by closures stay shared after LoadSnapshot
+type snapshot struct {
+	Version int                          `json:"version"`
+	Frames  []map[String]*snapshotObject `json:"frames"`
+	Stack   []int                        `json:"stack"`
+}
+
+type snapshotObject struct {
+	Type     String            `json:"type"`
+	Int      Int               `json:"int,omitempty"`
+	Str      String            `json:"str,omitempty"`
+	Items    []*snapshotObject `json:"items,omitempty"` // List elements, Dict keys and values alternate
+	Params   []String          `json:"params,omitempty"`
+	Defaults map[String]string `json:"defaults,omitempty"` // source code of Lambda.Defaults
+	Rest     String            `json:"rest,omitempty"`
+	Impl     string            `json:"impl,omitempty"`  // source code of Lambda.Impl or Quote.Expr
+	Frame    *int              `json:"frame,omitempty"` // index of Lambda.Frame
+	Name     String            `json:"name,omitempty"`  // builtin Module or Macro name, ok or err for Result, some or none for Option
+}
+
+type snapshotEncoder struct {
+	frames  []map[String]*snapshotObject
+	indices map[uintptr]int
+	skipped []error // bindings that cannot be written, see Snapshot
+}
+
+func (e *snapshotEncoder) encodeFrame(f Frame) (int, error) {
+	ptr := reflect.ValueOf(f).Pointer()
+	if i, ok := e.indices[ptr]; ok {
+		return i, nil
+	}
+	i := len(e.frames)
+	e.indices[ptr] = i
+	e.frames = append(e.frames, nil) // reserve the index before encoding, frames may refer to themselves
+	encoded := make(map[String]*snapshotObject, len(f))
+	for name, o := range f {
+		so, err := e.encode(o)
+		if err != nil {
+			e.skipped = append(e.skipped, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		encoded[name] = so
+	}
+	e.frames[i] = encoded
+	return i, nil
+}
+
+func (e *snapshotEncoder) encode(o Object) (*snapshotObject, error) {
+	if o == nil {
+		return nil, nil
+	}
+	so := &snapshotObject{Type: getType(o)}
+	switch o := o.(type) {
+	case Int:
+		so.Int = o
+	case String:
+		so.Str = o
+	case Wildcard, Unwrap:
+	case List:
+		for _, elem := range o {
+			item, err := e.encode(elem)
+			if err != nil {
+				return nil, err
+			}
+			so.Items = append(so.Items, item)
+		}
+	case Dict:
+		for k, v := range o {
+			key, err := e.encode(k)
+			if err != nil {
+				return nil, err
+			}
+			value, err := e.encode(v)
+			if err != nil {
+				return nil, err
+			}
+			so.Items = append(so.Items, key, value)
+		}
+	case Quote:
+		so.Impl = o.Expr.String()
+	case Macro:
+		so.Name = o.Name
+		item, err := e.encode(o.Lambda)
+		if err != nil {
+			return nil, err
+		}
+		so.Items = []*snapshotObject{item}
+	case Lambda:
+		so.Params = o.Params
+		so.Rest = o.Rest
+		for name, d := range o.Defaults {
+			if so.Defaults == nil {
+				so.Defaults = make(map[String]string)
+			}
+			so.Defaults[name] = d.String()
+		}
+		so.Impl = o.Impl.String()
+		if o.Frame != nil {
+			i, err := e.encodeFrame(o.Frame)
+			if err != nil {
+				return nil, err
+			}
+			so.Frame = &i
+		}
+	case Memo:
+		// the cache is not kept
+		so.Int = Int(o.cache.size)
+		item, err := e.encode(o.Lambda)
+		if err != nil {
+			return nil, err
+		}
+		so.Items = []*snapshotObject{item}
+	case Module:
+		so.Name = o.Name
+	case Result, Optional:
+		var value Object
+		switch o := o.(type) {
+		case Result:
+			so.Name, value = "err", o.Value
+			if o.Ok {
+				so.Name = "ok"
+			}
+		case Optional:
+			so.Name, value = "none", o.Value
+			if o.Some {
+				so.Name = "some"
+			}
+		}
+		item, err := e.encode(value)
+		if err != nil {
+			return nil, err
+		}
+		so.Items = []*snapshotObject{item}
+	default:
+		return nil, fmt.Errorf("cannot snapshot %s", getType(o))
+	}
+	return so, nil
+}
+
+// Snapshot : write every frame of the stack, including closures and their code, as json
+//
+// modules are written by name, they must be loaded in the runtime calling LoadSnapshot.
+// bindings to values that only exist while the program runs, like streams, promises, futures and channels,
+// are not written, skipped has one error per binding left out
+func (r *Runtime) Snapshot(w io.Writer) (skipped []error, err error) {
+	e := &snapshotEncoder{
+		indices: make(map[uintptr]int),
+	}
+	s := snapshot{
+		Version: snapshotVersion,
+	}
+	for _, f := range r.Stack {
+		i, err := e.encodeFrame(f)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: %w", err)
+		}
+		s.Stack = append(s.Stack, i)
+	}
+	s.Frames = e.frames
+	sort.Slice(e.skipped, func(i, j int) bool {
+		return e.skipped[i].Error() < e.skipped[j].Error()
+	})
+	return e.skipped, json.NewEncoder(w).Encode(s)
+}
+
+type snapshotDecoder struct {
+	s       snapshot
+	frames  []Frame
+	modules map[String]Module
+}
+
+func (d *snapshotDecoder) decodeFrame(i int) (Frame, error) {
+	if i < 0 || i >= len(d.frames) {
+		return nil, fmt.Errorf("frame %d not found", i)
+	}
+	return d.frames[i], nil
+}
+
+func (d *snapshotDecoder) decode(so *snapshotObject) (Object, error) {
+	if so == nil {
+		return nil, nil
+	}
+	switch so.Type {
+	case "Int":
+		return so.Int, nil
+	case "String":
+		return so.Str, nil
+	case "Wildcard":
+		return Wildcard{}, nil
+	case "Unwrap":
+		return Unwrap{}, nil
+	case "List":
+		l := make(List, 0, len(so.Items))
+		for _, item := range so.Items {
+			o, err := d.decode(item)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, o)
+		}
+		return l, nil
+	case "Dict":
+		if len(so.Items)%2 != 0 {
+			return nil, fmt.Errorf("dict with odd number of items")
+		}
+		dict := make(Dict, len(so.Items)/2)
+		for i := 0; i < len(so.Items); i += 2 {
+			k, err := d.decode(so.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.decode(so.Items[i+1])
+			if err != nil {
+				return nil, err
+			}
+			dict[k] = v
+		}
+		return dict, nil
+	case "Lambda":
+		impl, err := parseSource(so.Impl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lambda implementation %s", so.Impl)
+		}
+		l := Lambda{
+			Params: so.Params,
+			Rest:   so.Rest,
+			Impl:   impl,
+			purity: &purityCache{},
+		}
+		for name, source := range so.Defaults {
+			if l.Defaults == nil {
+				l.Defaults = make(map[String]Expr)
+			}
+			if l.Defaults[name], err = parseSource(source); err != nil {
+				return nil, fmt.Errorf("invalid default value of %s: %s", name, source)
+			}
+		}
+		if so.Frame != nil {
+			l.Frame, err = d.decodeFrame(*so.Frame)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	case "Result", "Option":
+		if len(so.Items) != 1 {
+			return nil, fmt.Errorf("%s without value", so.Type)
+		}
+		value, err := d.decode(so.Items[0])
+		if err != nil {
+			return nil, err
+		}
+		if so.Type == "Result" {
+			return Result{Ok: so.Name == "ok", Value: value}, nil
+		}
+		return Optional{Some: so.Name == "some", Value: value}, nil
+	case "Expr":
+		e, err := parseSource(so.Impl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression %s", so.Impl)
+		}
+		return Quote{Expr: e}, nil
+	case "Macro":
+		if len(so.Items) != 1 {
+			return nil, fmt.Errorf("macro without lambda")
+		}
+		o, err := d.decode(so.Items[0])
+		if err != nil {
+			return nil, err
+		}
+		l, ok := o.(Lambda)
+		if !ok {
+			return nil, fmt.Errorf("macro without lambda")
+		}
+		return Macro{Name: so.Name, Lambda: l}, nil
+	case "Memo":
+		if len(so.Items) != 1 || so.Int <= 0 {
+			return nil, fmt.Errorf("memo without lambda")
+		}
+		o, err := d.decode(so.Items[0])
+		if err != nil {
+			return nil, err
+		}
+		l, ok := o.(Lambda)
+		if !ok {
+			return nil, fmt.Errorf("memo without lambda")
+		}
+		return newMemo(l, int(so.Int)), nil
+	case "Module":
+		m, ok := d.modules[so.Name]
+		if !ok {
+			return nil, fmt.Errorf("module %s is not loaded", so.Name)
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cannot load %s", so.Type)
+	}
+}
+
+// parseSource : parse exactly one expression
+func parseSource(source string) (Expr, error) {
+	expr, rest, err := parseSingle(Tokenize(source))
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("unexpected tokens after expression")
+	}
+	return expr, nil
+}
+
+// LoadSnapshot : replace the stack by the one written by Snapshot
+//
+// the bindings of the global frame are set over the current ones, so that builtins and functions
+// loaded with RegisterFunc that the snapshot does not have are kept
+func (r *Runtime) LoadSnapshot(reader io.Reader) error {
+	d := &snapshotDecoder{
+		modules: make(map[String]Module),
+	}
+	if err := json.NewDecoder(reader).Decode(&d.s); err != nil {
+		return fmt.Errorf("load snapshot: %w", err)
+	}
+	if d.s.Version != snapshotVersion {
+		return fmt.Errorf("load snapshot: unsupported version %d", d.s.Version)
+	}
+	if len(d.s.Stack) == 0 {
+		return fmt.Errorf("load snapshot: empty stack")
+	}
+	for _, f := range r.Stack {
+		for _, o := range f {
+			if m, ok := o.(Module); ok {
+				d.modules[m.Name] = m
+			}
+		}
+	}
+	// allocate every frame first so that closures can refer to any of them
+	for range d.s.Frames {
+		d.frames = append(d.frames, make(Frame))
+	}
+	for i, encoded := range d.s.Frames {
+		for name, so := range encoded {
+			o, err := d.decode(so)
+			if err != nil {
+				return fmt.Errorf("load snapshot: %s: %w", name, err)
+			}
+			d.frames[i][name] = o
+		}
+	}
+	var stack []Frame
+	for _, i := range d.s.Stack {
+		f, err := d.decodeFrame(i)
+		if err != nil {
+			return fmt.Errorf("load snapshot: %w", err)
+		}
+		stack = append(stack, f)
+	}
+	// the decoded global frame is kept since closures may refer to it, the current bindings are added to it
+	for name, o := range r.Stack[0] {
+		if _, ok := stack[0][name]; !ok {
+			stack[0][name] = o
+		}
+	}
+	r.Stack = stack
+	r.shared = 0
+	return nil
+}
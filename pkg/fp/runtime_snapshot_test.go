@@ -0,0 +1,67 @@
+package fp
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// eval : value of the last expression of source on r
+func eval(t *testing.T, r *Runtime, source string) Object {
+	t.Helper()
+	exprs, err := ParseSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o Object
+	for _, expr := range exprs {
+		if o, err = r.Step(context.Background(), expr); err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+	}
+	return o
+}
+
+func TestSnapshot(t *testing.T) {
+	r := NewStdRuntime()
+	eval(t, r, `(let n 10) (let addn (lambda x (add x n))) (let l (range-stream 1 3)) (let p (delay 1)) (let c (chan 1))`)
+	var b bytes.Buffer
+	skipped, err := r.Snapshot(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, err := range skipped {
+		got = append(got, err.Error())
+	}
+	if want := "c: cannot snapshot Channel, l: cannot snapshot Stream, p: cannot snapshot Promise"; strings.Join(got, ", ") != want {
+		t.Errorf("skipped %s, want %s", strings.Join(got, ", "), want)
+	}
+
+	loaded := NewStdRuntime()
+	if err := loaded.RegisterFunc("twice", func(x int) int { return 2 * x }, "(twice x)"); err != nil {
+		t.Fatal(err)
+	}
+	eval(t, loaded, `(let n 1) (let old 5)`)
+	if err := loaded.LoadSnapshot(&b); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(addn 1)`, "11"},
+		{`n`, "10"},
+		{`(twice (addn 1))`, "22"},
+		{`old`, "5"},
+	}
+	for _, test := range tests {
+		if got := eval(t, loaded, test.source).String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+	if _, err := loaded.searchOnStack("l"); err == nil {
+		t.Errorf("l is bound, want it skipped")
+	}
+}
@@ -0,0 +1,579 @@
+package fp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// streamCell : memoized cell of a lazy stream, next is called at most once by force
+//
+// next may return another lazy cell, force keeps evaluating until the cell is empty or has a head
+type streamCell struct {
+	mtx   sync.Mutex
+	done  bool
+	empty bool
+	head  Object
+	tail  *streamCell
+	next  func(ctx context.Context, r *Runtime) (*streamCell, error)
+	busy  chan struct{} // closed once the goroutine calling next is done, nil if none is
+}
+
+func cons(head Object, tail *streamCell) *streamCell {
+	return &streamCell{done: true, head: head, tail: tail}
+}
+
+func emptyCell() *streamCell {
+	return &streamCell{done: true, empty: true}
+}
+
+func lazyCell(next func(ctx context.Context, r *Runtime) (*streamCell, error)) *streamCell {
+	return &streamCell{next: next}
+}
+
+// force : head and tail of the cell, ok is false at the end of the stream
+//
+// the lock is not held while next runs, other goroutines wait for it and next forcing the cell itself is an error
+func (c *streamCell) force(ctx context.Context, r *Runtime) (head Object, tail *streamCell, ok bool, err error) {
+	for {
+		if err := contextError(ctx); err != nil {
+			return nil, nil, false, err
+		}
+		c.mtx.Lock()
+		if c.done {
+			defer c.mtx.Unlock()
+			return c.head, c.tail, !c.empty, nil
+		}
+		if busy := c.busy; busy != nil {
+			c.mtx.Unlock()
+			if err := waitForcing(ctx, c, busy, "stream"); err != nil {
+				return nil, nil, false, err
+			}
+			continue
+		}
+		next, busy := c.next, make(chan struct{})
+		c.busy = busy
+		c.mtx.Unlock()
+		n, err := next(withForcing(ctx, c), r)
+		if err == nil {
+			n.mtx.Lock()
+			done, empty, head, tail, next := n.done, n.empty, n.head, n.tail, n.next
+			n.mtx.Unlock()
+			c.mtx.Lock()
+			c.done, c.empty, c.head, c.tail, c.next = done, empty, head, tail, next
+			c.mtx.Unlock()
+		}
+		c.mtx.Lock()
+		c.busy = nil
+		c.mtx.Unlock()
+		close(busy)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+}
+
+// forcingKey : context key of the streams and promises being forced by the current evaluation
+type forcingKey struct{}
+
+type forcing struct {
+	value  any
+	parent *forcing
+}
+
+func withForcing(ctx context.Context, value any) context.Context {
+	parent, _ := ctx.Value(forcingKey{}).(*forcing)
+	return context.WithValue(ctx, forcingKey{}, &forcing{value: value, parent: parent})
+}
+
+// waitForcing : wait for another goroutine to compute value, an error if the current evaluation is the one computing it
+func waitForcing(ctx context.Context, value any, busy chan struct{}, kind string) error {
+	for f, _ := ctx.Value(forcingKey{}).(*forcing); f != nil; f = f.parent {
+		if f.value == value {
+			return fmt.Errorf("%s forced recursively", kind)
+		}
+	}
+	select {
+	case <-busy:
+		return nil
+	case <-ctx.Done():
+		return contextError(ctx)
+	}
+}
+
+// Stream : lazy, possibly infinite, sequence, elements are computed once when needed
+type Stream struct {
+	cell *streamCell
+}
+
+// maxStreamString : number of computed elements shown by Stream.String
+const maxStreamString = 16
+
+func (s Stream) String() string {
+	str := "(stream"
+	c := s.cell
+	for i := 0; ; i++ {
+		// never compute elements or wait for another goroutine to print a stream
+		if i == maxStreamString || !c.mtx.TryLock() {
+			return str + " ...)"
+		}
+		done, empty, head, tail := c.done, c.empty, c.head, c.tail
+		c.mtx.Unlock()
+		if !done {
+			return str + " ...)"
+		}
+		if empty {
+			return str + ")"
+		}
+		str += fmt.Sprintf(" %v", head)
+		c = tail
+	}
+}
+
+func (s Stream) MustTypeObject() {}
+
+// listCell : stream of the elements of l from index i
+func listCell(l List, i int) *streamCell {
+	if i >= len(l) {
+		return emptyCell()
+	}
+	return cons(l[i], lazyCell(func(ctx context.Context, r *Runtime) (*streamCell, error) {
+		return listCell(l, i+1), nil
+	}))
+}
+
+// toStreamCell : first cell of a stream or a list
+func toStreamCell(o Object) (*streamCell, bool) {
+	switch o := o.(type) {
+	case Stream:
+		return o.cell, true
+	case List:
+		return listCell(o, 0), true
+	default:
+		return nil, false
+	}
+}
+
+// toList : elements of a list or a finite stream, ok is false for other objects
+func toList(ctx context.Context, r *Runtime, o Object) (l List, ok bool, err error) {
+	switch o := o.(type) {
+	case List:
+		return o, true, nil
+	case Stream:
+		l = List{}
+		for c := o.cell; ; {
+			head, tail, ok, err := c.force(ctx, r)
+			if err != nil {
+				return nil, true, err
+			}
+			if !ok {
+				return l, true, nil
+			}
+			l = append(l, head)
+			c = tail
+		}
+	default:
+		return nil, false, nil
+	}
+}
+
+// extensionList : toList for the arguments of an extension
+func extensionList(ctx context.Context, o Object) (List, bool, error) {
+	if _, ok := o.(Stream); !ok {
+		l, ok := o.(List)
+		return l, ok, nil
+	}
+	return toList(ctx, mustGetExtensionContext(ctx).Runtime(), o)
+}
+
+// truthy : predicates of streams return 0 for false
+func truthy(o Object) (bool, error) {
+	i, ok := o.(Int)
+	if !ok {
+		return false, fmt.Errorf("predicate must return integer, got %s", getType(o))
+	}
+	return i != 0, nil
+}
+
+// rangeArgs : low, high and the optional step of range and range-stream
+func rangeArgs(name String, values []Object) (low Int, high Int, step Int, err error) {
+	if err := checkArgs(name, len(values), 2, 3); err != nil {
+		return 0, 0, 0, err
+	}
+	low, ok := values[0].(Int)
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("first argument must be integer")
+	}
+	high, ok = values[1].(Int)
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("second argument must be integer")
+	}
+	step = Int(1)
+	if len(values) == 3 {
+		if step, ok = values[2].(Int); !ok || step <= 0 {
+			return 0, 0, 0, fmt.Errorf("third argument must be positive integer")
+		}
+	}
+	return low, high, step, nil
+}
+
+var rangeExtension = Extension{
+	Name: "range",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		low, high, step, err := rangeArgs("range", values)
+		if err != nil {
+			return nil, err
+		}
+		if low > high {
+			return nil, nil
+		}
+		var list List
+		for i := low; i <= high; i += step {
+			list = append(list, i)
+		}
+		return list, nil
+	},
+	Man: "module: (range 1 10) - return [1, 2, ..., 10], (range 1 10 2) returns [1, 3, ..., 9]",
+}
+
+var rangeStreamExtension = Extension{
+	Name: "range-stream",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		low, high, step, err := rangeArgs("range-stream", values)
+		if err != nil {
+			return nil, err
+		}
+		var from func(i Int) *streamCell
+		from = func(i Int) *streamCell {
+			if i > high {
+				return emptyCell()
+			}
+			return cons(i, lazyCell(func(ctx context.Context, r *Runtime) (*streamCell, error) {
+				return from(i + step), nil
+			}))
+		}
+		return Stream{cell: from(low)}, nil
+	},
+	Man: "module: (range-stream 1 10 2) - lazy stream 1, 3, ..., 9, the step is optional",
+}
+
+var iterateExtension = Extension{
+	Name: "iterate",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("iterate", len(values), 2, 2); err != nil {
+			return nil, err
+		}
+		f := values[0]
+		var from func(x Object) *streamCell
+		from = func(x Object) *streamCell {
+			return cons(x, lazyCell(func(ctx context.Context, r *Runtime) (*streamCell, error) {
+				y, err := r.Apply(ctx, f, x)
+				if err != nil {
+					return nil, err
+				}
+				return from(y), nil
+			}))
+		}
+		return Stream{cell: from(values[1])}, nil
+	},
+	Man: "module: (iterate (lambda x (mul x 2)) 1) - infinite stream 1, 2, 4, 8, ...",
+}
+
+var repeatExtension = Extension{
+	Name: "repeat",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("repeat", len(values), 1, 2); err != nil {
+			return nil, err
+		}
+		x := values[0]
+		n := Int(-1)
+		if len(values) == 2 {
+			var ok bool
+			if n, ok = values[1].(Int); !ok || n < 0 {
+				return nil, fmt.Errorf("second argument must be non-negative integer")
+			}
+		}
+		var from func(n Int) *streamCell
+		from = func(n Int) *streamCell {
+			if n == 0 {
+				return emptyCell()
+			}
+			return cons(x, lazyCell(func(ctx context.Context, r *Runtime) (*streamCell, error) {
+				return from(n - 1), nil
+			}))
+		}
+		return Stream{cell: from(n)}, nil
+	},
+	Man: "module: (repeat 0 3) - stream 0, 0, 0, infinite without count",
+}
+
+var cycleExtension = Extension{
+	Name: "cycle",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("cycle", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		start, ok := toStreamCell(values[0])
+		if !ok {
+			return nil, fmt.Errorf("first argument must be list or stream")
+		}
+		var from func(c *streamCell, yielded bool) *streamCell
+		from = func(c *streamCell, yielded bool) *streamCell {
+			return lazyCell(func(ctx context.Context, r *Runtime) (*streamCell, error) {
+				head, tail, ok, err := c.force(ctx, r)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					if !yielded {
+						return emptyCell(), nil
+					}
+					return from(start, false), nil
+				}
+				return cons(head, from(tail, true)), nil
+			})
+		}
+		return Stream{cell: from(start, false)}, nil
+	},
+	Man: "module: (cycle (list 1 2)) - infinite stream 1, 2, 1, 2, ...",
+}
+
+var streamMapExtension = Extension{
+	Name: "stream-map",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("stream-map", len(values), 2, 2); err != nil {
+			return nil, err
+		}
+		start, ok := toStreamCell(values[0])
+		if !ok {
+			return nil, fmt.Errorf("first argument must be list or stream")
+		}
+		f := values[1]
+		var from func(c *streamCell) *streamCell
+		from = func(c *streamCell) *streamCell {
+			return lazyCell(func(ctx context.Context, r *Runtime) (*streamCell, error) {
+				head, tail, ok, err := c.force(ctx, r)
+				if err != nil || !ok {
+					return emptyCell(), err
+				}
+				y, err := r.Apply(ctx, f, head)
+				if err != nil {
+					return nil, err
+				}
+				return cons(y, from(tail)), nil
+			})
+		}
+		return Stream{cell: from(start)}, nil
+	},
+	Man: "module: (stream-map s (lambda x (mul x x))) - lazy map, f is called when an element is needed",
+}
+
+var streamFilterExtension = Extension{
+	Name: "stream-filter",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("stream-filter", len(values), 2, 2); err != nil {
+			return nil, err
+		}
+		start, ok := toStreamCell(values[0])
+		if !ok {
+			return nil, fmt.Errorf("first argument must be list or stream")
+		}
+		f := values[1]
+		var from func(c *streamCell) *streamCell
+		from = func(c *streamCell) *streamCell {
+			return lazyCell(func(ctx context.Context, r *Runtime) (*streamCell, error) {
+				for {
+					head, tail, ok, err := c.force(ctx, r)
+					if err != nil || !ok {
+						return emptyCell(), err
+					}
+					keep, err := r.Apply(ctx, f, head)
+					if err != nil {
+						return nil, err
+					}
+					if t, err := truthy(keep); err != nil {
+						return nil, err
+					} else if t {
+						return cons(head, from(tail)), nil
+					}
+					c = tail
+				}
+			})
+		}
+		return Stream{cell: from(start)}, nil
+	},
+	Man: "module: (stream-filter s (lambda x (mod x 2))) - lazy filter, keep elements where f is not 0",
+}
+
+var takeExtension = Extension{
+	Name: "take",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("take", len(values), 2, 2); err != nil {
+			return nil, err
+		}
+		start, ok := toStreamCell(values[0])
+		if !ok {
+			return nil, fmt.Errorf("first argument must be list or stream")
+		}
+		n, ok := values[1].(Int)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("second argument must be non-negative integer")
+		}
+		var from func(c *streamCell, n Int) *streamCell
+		from = func(c *streamCell, n Int) *streamCell {
+			if n == 0 {
+				return emptyCell()
+			}
+			return lazyCell(func(ctx context.Context, r *Runtime) (*streamCell, error) {
+				head, tail, ok, err := c.force(ctx, r)
+				if err != nil || !ok {
+					return emptyCell(), err
+				}
+				return cons(head, from(tail, n-1)), nil
+			})
+		}
+		return Stream{cell: from(start, n)}, nil
+	},
+	Man: "module: (take s 10) - stream of the first 10 elements of s",
+}
+
+var takeWhileExtension = Extension{
+	Name: "take-while",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("take-while", len(values), 2, 2); err != nil {
+			return nil, err
+		}
+		start, ok := toStreamCell(values[0])
+		if !ok {
+			return nil, fmt.Errorf("first argument must be list or stream")
+		}
+		f := values[1]
+		var from func(c *streamCell) *streamCell
+		from = func(c *streamCell) *streamCell {
+			return lazyCell(func(ctx context.Context, r *Runtime) (*streamCell, error) {
+				head, tail, ok, err := c.force(ctx, r)
+				if err != nil || !ok {
+					return emptyCell(), err
+				}
+				keep, err := r.Apply(ctx, f, head)
+				if err != nil {
+					return nil, err
+				}
+				if t, err := truthy(keep); err != nil || !t {
+					return emptyCell(), err
+				}
+				return cons(head, from(tail)), nil
+			})
+		}
+		return Stream{cell: from(start)}, nil
+	},
+	Man: "module: (take-while s (lambda x (sub x 100))) - stream of the first elements of s while f is not 0, here until 100",
+}
+
+var toListExtension = Extension{
+	Name: "to-list",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("to-list", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		l, ok, err := extensionList(ctx, values[0])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("first argument must be list or stream")
+		}
+		return l, nil
+	},
+	Man: "module: (to-list (take s 10)) - compute every element of a finite stream",
+}
+
+// Promise : expression evaluated once by force, see delay
+type Promise struct {
+	state *promiseState
+}
+
+type promiseState struct {
+	mtx   sync.Mutex
+	done  bool
+	value Object
+	f     Lambda
+	busy  chan struct{} // closed once the goroutine evaluating f is done, nil if none is
+}
+
+func (p Promise) String() string {
+	if p.state.mtx.TryLock() {
+		defer p.state.mtx.Unlock()
+		if p.state.done {
+			return fmt.Sprintf("(promise %v)", p.state.value)
+		}
+	}
+	return "(promise ...)"
+}
+
+func (p Promise) MustTypeObject() {}
+
+var delayModule = Module{
+	Name: "delay",
+	Exec: func(ctx context.Context, r *Runtime, expr LambdaExpr) (Object, error) {
+		if err := checkArgs("delay", len(expr.Args), 1, 1); err != nil {
+			return nil, err
+		}
+		// same scope as (lambda expr)
+		f, err := lambdaModule.Exec(ctx, r, LambdaExpr{Name: "lambda", Args: expr.Args})
+		if err != nil {
+			return nil, err
+		}
+		return Promise{state: &promiseState{f: f.(Lambda)}}, nil
+	},
+	Man: "module: (delay (add 1 2)) - promise of the value of an expression, computed by force",
+}
+
+var forceExtension = Extension{
+	Name: "force",
+	Exec: func(ctx context.Context, values ...Object) (Object, error) {
+		if err := checkArgs("force", len(values), 1, 1); err != nil {
+			return nil, err
+		}
+		p, ok := values[0].(Promise)
+		if !ok {
+			// like other lisps, forcing a value gives the value
+			return values[0], nil
+		}
+		return p.force(ctx)
+	},
+	Man: "module: (force p) - value of a promise made by delay, computed the first time only",
+}
+
+// force : value of the promise, the lock is not held while f runs, like streamCell.force
+func (p Promise) force(ctx context.Context) (Object, error) {
+	s := p.state
+	for {
+		s.mtx.Lock()
+		if s.done {
+			defer s.mtx.Unlock()
+			return s.value, nil
+		}
+		if busy := s.busy; busy != nil {
+			s.mtx.Unlock()
+			if err := waitForcing(ctx, s, busy, "promise"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		f, busy := s.f, make(chan struct{})
+		s.busy = busy
+		s.mtx.Unlock()
+		v, err := mustGetExtensionContext(ctx).Runtime().Apply(withForcing(ctx, s), f)
+		s.mtx.Lock()
+		if err == nil {
+			s.done, s.value, s.f = true, v, Lambda{}
+		}
+		s.busy = nil
+		s.mtx.Unlock()
+		close(busy)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package fp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestForceRecursively(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{`(let s (iterate (lambda x (peek s 1)) 0)) (peek s 1)`, "stream forced recursively"},
+		{`(let p (delay (force p))) (force p)`, "promise forced recursively"},
+	}
+	for _, test := range tests {
+		_, err := run(t, test.source)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.source, err, test.err)
+		}
+	}
+}
+
+func TestForceOnce(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		// the expression of a promise is evaluated once
+		{`(let n 0) (let p (delay (let n (add n 1)))) (force p) (force p)`, "1"},
+		{`(let s (iterate (lambda x (add x 1)) 0)) (to-list (take s 5))`, "[0,1,2,3,4,]"},
+		// futures forcing the same stream wait for each other
+		{`(let s (range-stream 0 99)) (let fs (map (list 1 2 3 4) (lambda i (spawn len s)))) (map fs (lambda f (await f)))`, "[100,100,100,100,]"},
+	}
+	for _, test := range tests {
+		o, err := run(t, test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(range 1 3)`, "[1,2,3,]"},
+		{`(range 1 10 4)`, "[1,5,9,]"},
+		{`(type (range 1 3))`, "List"},
+		{`(case (range 1 2) (list 1 2) 1 _ 0)`, "1"},
+		{`(type (range-stream 1 3))`, "Stream"},
+		{`(to-list (range-stream 1 10 4))`, "[1,5,9,]"},
+		{`(to-list (take (range-stream 1 1000000000) 3))`, "[1,2,3,]"},
+	}
+	for _, test := range tests {
+		o, err := run(t, test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if got := o.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package fp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// run : value of the last expression of source on a new runtime, the test fails if it does not end in time
+func run(t *testing.T, source string, opts ...Option) (Object, error) {
+	t.Helper()
+	exprs, err := ParseSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r := NewStdRuntime(opts...)
+	var o Object
+	for _, expr := range exprs {
+		if o, err = r.Step(ctx, expr); err != nil {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("%s: did not end in time", source)
+	}
+	return o, err
+}
@@ -0,0 +1,365 @@
+package lint
+
+import (
+	"fmt"
+	"fp/pkg/fp"
+	"strconv"
+	"strings"
+)
+
+// arity : number of arguments accepted by a builtin, max is -1 without limit
+type arity struct {
+	min, max int
+}
+
+// arities : builtins checking their number of arguments, calls with * are not checked
+var arities = map[string]arity{
+	"let": {2, -1}, "del": {1, -1}, "lambda": {1, -1}, "case": {1, -1}, "with": {1, -1}, "letrec": {1, -1},
+	"defmacro": {2, -1}, "quote": {1, 1}, "quasiquote": {1, 1}, "macroexpand": {1, 1}, "delay": {1, 1},
+	"gensym": {0, 1}, "expr-name": {1, 1}, "expr-args": {1, 1}, "raise": {1, 1}, "try": {1, -1},
+	"sub": {2, 2}, "div": {2, 2}, "mod": {2, 2}, "sign": {1, 1}, "slice": {3, 3}, "peek": {2, -1}, "len": {1, 1},
+	"map": {2, 2}, "read-line": {0, 0}, "rand": {1, 1}, "time": {0, 0}, "stack": {0, 0}, "doom": {0, 0}, "kaboom": {0, 0},
+	"tail": {1, -1}, "append": {1, -1}, "select": {1, -1}, "pure?": {1, 1}, "purity": {1, 1},
+	"memo": {1, 2}, "memo-stats": {1, 1}, "spawn": {1, -1}, "await": {1, 2}, "chan": {0, 1}, "send": {2, 2},
+	"recv": {1, 1}, "close": {1, 1}, "ok": {1, 1}, "err": {1, 1}, "some": {1, 1}, "none": {0, 0},
+	"unwrap": {1, 1}, "unwrap-or": {2, 2}, "map-ok": {2, 2}, "try-call": {1, -1}, "range": {2, 3}, "range-stream": {2, 3},
+	"iterate": {2, 2}, "repeat": {1, 2}, "cycle": {1, 1}, "stream-map": {2, 2}, "stream-filter": {2, 2},
+	"take": {2, 2}, "take-while": {2, 2}, "to-list": {1, 1}, "force": {1, 1},
+}
+
+func (a arity) String() string {
+	switch {
+	case a.max < 0:
+		return fmt.Sprintf("at least %d", a.min)
+	case a.min == a.max:
+		return strconv.Itoa(a.min)
+	default:
+		return fmt.Sprintf("%d to %d", a.min, a.max)
+	}
+}
+
+// scope : names bound by a lambda, a with or a letrec, or the program for the outermost one
+type scope struct {
+	names  map[string]bool
+	parent *scope
+	lambda bool // the body runs when the lambda is called, once every let of the program is done
+}
+
+func (s *scope) bound(name string) bool {
+	for ; s != nil; s = s.parent {
+		if s.names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scope) inLambda() bool {
+	for ; s != nil; s = s.parent {
+		if s.lambda {
+			return true
+		}
+	}
+	return false
+}
+
+type linter struct {
+	builtins    fp.Frame
+	pos         positions
+	defined     map[string]bool // names of every let and defmacro of the program
+	macros      map[string]bool // their arguments are not evaluated
+	diagnostics []Diagnostic
+}
+
+func (l *linter) report(pos fp.Pos, rule string, format string, args ...any) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Line:     pos.Line,
+		Col:      pos.Col,
+		Rule:     rule,
+		Severity: severity(rule),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// literal : names evaluated without lookup, and keywords of calls
+func literal(name string) bool {
+	if _, err := strconv.Atoi(name); err == nil {
+		return true
+	}
+	return name == "_" || name == "*" || strings.HasPrefix(name, `"`) || strings.HasPrefix(name, ":")
+}
+
+func (l *linter) check(exprs []fp.Expr) {
+	l.defined, l.macros = make(map[string]bool), make(map[string]bool)
+	var collect func(exprs []fp.Expr)
+	collect = func(exprs []fp.Expr) {
+		for _, expr := range exprs {
+			e, ok := expr.(fp.LambdaExpr)
+			if !ok {
+				continue
+			}
+			if name, ok := bindingName(e); ok && (e.Name == "let" || e.Name == "defmacro") {
+				l.defined[name] = true
+				if e.Name == "defmacro" {
+					l.macros[name] = true
+				}
+			}
+			collect(e.Args)
+		}
+	}
+	collect(exprs)
+	program := &scope{names: make(map[string]bool)}
+	for i, expr := range exprs {
+		pos := fp.Pos{}
+		if i < len(l.pos.toplevel) {
+			pos = l.pos.toplevel[i]
+		}
+		l.expr(program, expr, pos)
+	}
+}
+
+// bindingName : first argument of let, del or defmacro
+func bindingName(e fp.LambdaExpr) (string, bool) {
+	if len(e.Args) == 0 {
+		return "", false
+	}
+	name, ok := e.Args[0].(fp.NameExpr)
+	return string(name), ok
+}
+
+// name : a name is bound if a scope has it, or a builtin, or, in a lambda, any let of the program since it runs later
+func (l *linter) name(s *scope, name string, pos fp.Pos) {
+	if literal(name) || s.bound(name) {
+		return
+	}
+	if _, ok := l.builtins[fp.String(name)]; ok {
+		return
+	}
+	if s.inLambda() && l.defined[name] {
+		return
+	}
+	if l.defined[name] {
+		l.report(pos, "unbound", "%s is used before its let", name)
+		return
+	}
+	l.report(pos, "unbound", "%s is not defined", name)
+}
+
+func (l *linter) bind(s *scope, name string, pos fp.Pos) {
+	if _, ok := l.builtins[fp.String(name)]; ok {
+		l.report(pos, "shadow", "%s shadows the builtin %s", name, name)
+	}
+	s.names[name] = true
+}
+
+func (l *linter) expr(s *scope, expr fp.Expr, pos fp.Pos) {
+	switch e := expr.(type) {
+	case fp.NameExpr:
+		l.name(s, string(e), pos)
+	case fp.LambdaExpr:
+		l.call(s, e)
+	}
+}
+
+// args : every argument from i
+func (l *linter) args(s *scope, e fp.LambdaExpr, i int) {
+	for ; i < len(e.Args); i++ {
+		l.expr(s, e.Args[i], l.pos.arg(e, i+1))
+	}
+}
+
+func (l *linter) call(s *scope, e fp.LambdaExpr) {
+	name := string(e.Name)
+	if !s.bound(name) && !l.defined[name] {
+		l.arity(e)
+	}
+	switch {
+	case l.macros[name]:
+		// arguments are expressions given to the macro
+		l.name(s, name, e.Pos)
+		return
+	case s.bound(name) || l.defined[name]:
+		// redefined by the program, an ordinary call
+	case name == "quote":
+		return
+	case name == "quasiquote" || name == "unquote" || name == "unquote-splicing":
+		l.template(s, e)
+		return
+	case name == "let" || name == "del":
+		binding, ok := bindingName(e)
+		if !ok || literal(binding) {
+			if len(e.Args) > 0 {
+				l.report(l.pos.arg(e, 1), "binding-name", "%s: argument 1 must be a name, got %s", name, e.Args[0])
+			}
+			l.args(s, e, 1)
+			return
+		}
+		l.args(s, e, 1)
+		if name == "let" {
+			l.bind(s, binding, l.pos.arg(e, 1))
+		}
+		return
+	case name == "lambda":
+		l.lambda(s, e, 0)
+		return
+	case name == "defmacro":
+		if binding, ok := bindingName(e); ok && !literal(binding) {
+			l.bind(s, binding, l.pos.arg(e, 1))
+		} else if len(e.Args) > 0 {
+			l.report(l.pos.arg(e, 1), "binding-name", "defmacro: argument 1 must be a name, got %s", e.Args[0])
+		}
+		l.lambda(s, e, 1)
+		return
+	case name == "with" || name == "letrec":
+		l.bindings(s, e)
+		return
+	case name == "case":
+		if len(e.Args) > 0 && len(e.Args)%2 == 0 {
+			l.report(l.pos.arg(e, len(e.Args)), "case-arity", "case has a pattern without result, %s", e.Args[len(e.Args)-1])
+		}
+	case name == "try":
+		l.try(s, e)
+		return
+	case name == "select":
+		l.selectClauses(s, e)
+		return
+	}
+	l.name(s, name, e.Pos)
+	l.args(s, e, 0)
+}
+
+// arity : calls of builtins with a wrong number of arguments, the program did not redefine them
+func (l *linter) arity(e fp.LambdaExpr) {
+	a, ok := arities[string(e.Name)]
+	if !ok {
+		return
+	}
+	for _, arg := range e.Args {
+		if name, ok := arg.(fp.NameExpr); ok && (name == "*" || strings.HasPrefix(string(name), ":")) {
+			// unwrapped lists and keywords are only known at runtime
+			return
+		}
+	}
+	if n := len(e.Args); n < a.min || (a.max >= 0 && n > a.max) {
+		l.report(e.Pos, "arity", "%s requires %s arguments, got %d", e.Name, a, n)
+	}
+}
+
+// lambda : parameters from the i-th argument, the last argument is the body
+func (l *linter) lambda(s *scope, e fp.LambdaExpr, i int) {
+	if len(e.Args) <= i {
+		return
+	}
+	inner := &scope{names: make(map[string]bool), parent: s, lambda: true}
+	for j := i; j < len(e.Args)-1; j++ {
+		pos := l.pos.arg(e, j+1)
+		switch p := e.Args[j].(type) {
+		case fp.NameExpr:
+			switch {
+			case p == "*":
+			case literal(string(p)):
+				l.report(pos, "binding-name", "lambda: argument %d must be a name, got %s", j-i+1, p)
+			default:
+				l.bind(inner, string(p), pos)
+			}
+		case fp.LambdaExpr:
+			// (name default), the default is evaluated when the lambda is called
+			for _, d := range p.Args {
+				l.expr(inner, d, l.pos.arg(p, 1))
+			}
+			if literal(string(p.Name)) {
+				l.report(pos, "binding-name", "lambda: argument %d must be a name, got %s", j-i+1, p.Name)
+				continue
+			}
+			l.bind(inner, string(p.Name), pos)
+		}
+	}
+	l.expr(inner, e.Args[len(e.Args)-1], l.pos.arg(e, len(e.Args)))
+}
+
+// bindings : (name expr) of with and letrec, then the body
+func (l *linter) bindings(s *scope, e fp.LambdaExpr) {
+	inner := &scope{names: make(map[string]bool), parent: s}
+	if len(e.Args) == 0 {
+		return
+	}
+	var bindings []fp.LambdaExpr
+	for i, arg := range e.Args[:len(e.Args)-1] {
+		b, ok := arg.(fp.LambdaExpr)
+		if !ok || len(b.Args) != 1 || literal(string(b.Name)) {
+			l.report(l.pos.arg(e, i+1), "binding-name", "%s: binding must be of the form (name expr), got %s", e.Name, arg)
+			continue
+		}
+		bindings = append(bindings, b)
+	}
+	if e.Name == "letrec" {
+		for _, b := range bindings {
+			l.bind(inner, string(b.Name), b.Pos)
+		}
+	}
+	for _, b := range bindings {
+		l.expr(inner, b.Args[0], l.pos.arg(b, 1))
+		if e.Name == "with" {
+			l.bind(inner, string(b.Name), b.Pos)
+		}
+	}
+	l.expr(inner, e.Args[len(e.Args)-1], l.pos.arg(e, len(e.Args)))
+}
+
+// template : only unquoted expressions of a quasiquote are evaluated
+func (l *linter) template(s *scope, e fp.LambdaExpr) {
+	if e.Name == "unquote" || e.Name == "unquote-splicing" {
+		l.args(s, e, 0)
+		return
+	}
+	for _, arg := range e.Args {
+		if a, ok := arg.(fp.LambdaExpr); ok {
+			if a.Name == "quasiquote" {
+				// nested templates are not evaluated
+				continue
+			}
+			l.template(s, a)
+		}
+	}
+}
+
+// try : (try expr ... (catch e expr ...) (finally expr ...))
+func (l *linter) try(s *scope, e fp.LambdaExpr) {
+	l.name(s, "try", e.Pos)
+	for i, arg := range e.Args {
+		c, ok := arg.(fp.LambdaExpr)
+		switch {
+		case ok && c.Name == "catch":
+			inner := &scope{names: make(map[string]bool), parent: s}
+			if name, ok := bindingName(c); ok {
+				inner.names[name] = true
+			}
+			l.args(inner, c, 1)
+		case ok && c.Name == "finally":
+			l.args(s, c, 0)
+		default:
+			l.expr(s, arg, l.pos.arg(e, i+1))
+		}
+	}
+}
+
+// selectClauses : (select (recv c x expr ...) (send c v expr ...) (timeout ms expr ...) (default expr ...))
+func (l *linter) selectClauses(s *scope, e fp.LambdaExpr) {
+	l.name(s, "select", e.Pos)
+	for _, arg := range e.Args {
+		c, ok := arg.(fp.LambdaExpr)
+		if !ok {
+			continue
+		}
+		if c.Name != "recv" || len(c.Args) < 2 {
+			l.args(s, c, 0)
+			continue
+		}
+		l.expr(s, c.Args[0], l.pos.arg(c, 1))
+		inner := &scope{names: make(map[string]bool), parent: s}
+		if name, ok := c.Args[1].(fp.NameExpr); ok {
+			inner.names[string(name)] = true
+		}
+		l.args(inner, c, 2)
+	}
+}
@@ -0,0 +1,155 @@
+package lint
+
+import (
+	"errors"
+	"fp/pkg/fp"
+	"sort"
+	"strings"
+)
+
+// Rule : a check of the linter, disabled with // lint:disable name
+type Rule struct {
+	Name     string
+	Severity string // error or warning
+	Doc      string
+}
+
+// Rules : every rule, in the order they are documented
+var Rules = []Rule{
+	{Name: "parse", Severity: "error", Doc: "the source does not parse"},
+	{Name: "unbound", Severity: "error", Doc: "name not defined by a builtin, let, a parameter or a binding"},
+	{Name: "case-arity", Severity: "error", Doc: "case with a pattern without result"},
+	{Name: "binding-name", Severity: "error", Doc: "let, del, defmacro, a parameter or a binding of with or letrec does not bind a name"},
+	{Name: "arity", Severity: "error", Doc: "wrong number of arguments for a builtin"},
+	{Name: "shadow", Severity: "warning", Doc: "let, parameter or binding hiding a builtin"},
+}
+
+func severity(rule string) string {
+	for _, r := range Rules {
+		if r.Name == rule {
+			return r.Severity
+		}
+	}
+	return "error"
+}
+
+// Diagnostic : problem found by a rule
+type Diagnostic struct {
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line"`
+	Col      int    `json:"col"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// Source : diagnostics of a whole source file, sorted by position
+//
+// a comment // lint:disable rule1,rule2 disables rules on its line, or on the next line if it is alone on its line,
+// // lint:disable-file rule1,rule2 disables them in the whole file, without rule every rule is disabled
+func Source(source string) []Diagnostic {
+	l := &linter{builtins: fp.NewStdRuntime().Stack[0]}
+	exprs, err := fp.ParseSource(source)
+	var parseErr *fp.ParseError
+	if errors.As(err, &parseErr) {
+		l.report(parseErr.Pos, "parse", "%s", parseErr.Err)
+	}
+	tokens, posList := fp.TokenizePos(source)
+	l.pos = newPositions(tokens, posList)
+	l.check(exprs)
+
+	disabled := disabledRules(source)
+	var diagnostics []Diagnostic
+	for _, d := range l.diagnostics {
+		if disabled.has(d.Line, d.Rule) {
+			continue
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Col < diagnostics[j].Col
+	})
+	return diagnostics
+}
+
+// Failed : there is an error, or a warning if strict
+func Failed(diagnostics []Diagnostic, strict bool) bool {
+	for _, d := range diagnostics {
+		if d.Severity == "error" || strict {
+			return true
+		}
+	}
+	return false
+}
+
+// disabled : rules disabled by line, line 0 for the whole file, an empty set disables every rule
+type disabled map[int]map[string]bool
+
+func (d disabled) has(line int, rule string) bool {
+	for _, l := range []int{0, line} {
+		if rules, ok := d[l]; ok && (len(rules) == 0 || rules[rule]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d disabled) add(line int, list string) {
+	names := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	rules, ok := d[line]
+	switch {
+	case ok && len(rules) == 0:
+		// every rule is already disabled
+	case len(names) == 0:
+		d[line] = make(map[string]bool)
+	default:
+		if !ok {
+			rules = make(map[string]bool)
+			d[line] = rules
+		}
+		for _, name := range names {
+			rules[name] = true
+		}
+	}
+}
+
+func disabledRules(source string) disabled {
+	d := make(disabled)
+	tokens, posList := fp.TokenizeComments(source)
+	for i, token := range tokens {
+		text, ok := strings.CutPrefix(token, "//")
+		if !ok {
+			continue
+		}
+		text = strings.TrimSpace(text)
+		if list, ok := strings.CutPrefix(text, "lint:disable-file"); ok {
+			d.add(0, list)
+			continue
+		}
+		list, ok := strings.CutPrefix(text, "lint:disable")
+		if !ok {
+			continue
+		}
+		line := posList[i].Line
+		if i == 0 || posList[i-1].Line != line {
+			// alone on its line, the next line with code
+			line = 0
+			for j := i + 1; j < len(tokens); j++ {
+				if !strings.HasPrefix(tokens[j], "//") {
+					line = posList[j].Line
+					break
+				}
+			}
+			if line == 0 {
+				continue
+			}
+		}
+		d.add(line, list)
+	}
+	return d
+}
@@ -0,0 +1,68 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string // line:col severity rule: message, one per line
+	}{
+		{`(let x 1) (add x 2)`, ""},
+		// parse
+		{`(add 1`, "1:1 error parse: missing )"},
+		// unbound
+		{`(add y 1)`, "1:6 error unbound: y is not defined"},
+		{`(let f (lambda n (add n m)))`, "1:25 error unbound: m is not defined"},
+		{`(with (a 1) a) a`, "1:16 error unbound: a is not defined"},
+		// case-arity
+		{`(case 1 2)`, "1:9 error case-arity: case has a pattern without result, 2"},
+		// binding-name
+		{`(let 3 4)`, "1:6 error binding-name: let: argument 1 must be a name, got 3"},
+		{`(del "s")`, `1:6 error binding-name: del: argument 1 must be a name, got "s"`},
+		{`(lambda x _ x)`, "1:11 error binding-name: lambda: argument 2 must be a name, got _"},
+		{`(with (3 4) 1)`, "1:7 error binding-name: with: binding must be of the form (name expr), got (3 4)"},
+		// arity
+		{`(sub 1)`, "1:1 error arity: sub requires 2 arguments, got 1"},
+		{`(range-stream 1)`, "1:1 error arity: range-stream requires 2 to 3 arguments, got 1"},
+		// shadow
+		{`(let map 1)`, "1:6 warning shadow: map shadows the builtin map"},
+		{`(let f (lambda list list))`, "1:16 warning shadow: list shadows the builtin list"},
+		// lint:disable
+		{"(let map 1) // lint:disable shadow", ""},
+		{"// lint:disable shadow\n(let map 1)", ""},
+		{"// lint:disable-file\n(add y 1)\n(let map 1)", ""},
+		{"(let map 1) // lint:disable unbound", "1:6 warning shadow: map shadows the builtin map"},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, d := range Source(test.source) {
+			got = append(got, fmt.Sprintf("%d:%d %s %s: %s", d.Line, d.Col, d.Severity, d.Rule, d.Message))
+		}
+		if got := strings.Join(got, "\n"); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestFailed(t *testing.T) {
+	tests := []struct {
+		source string
+		strict bool
+		want   bool
+	}{
+		{`(add 1 2)`, false, false},
+		{`(add 1 2)`, true, false},
+		{`(add y 2)`, false, true},
+		{`(let map 1)`, false, false},
+		{`(let map 1)`, true, true},
+	}
+	for _, test := range tests {
+		if got := Failed(Source(test.source), test.strict); got != test.want {
+			t.Errorf("%s with strict %v: got %v, want %v", test.source, test.strict, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package lint
+
+import "fp/pkg/fp"
+
+// positions : position of every element of every call, names have no position in the parsed expressions
+//
+// calls are found by fp.LambdaExpr.Pos, their first element is the name, the next ones are the arguments
+type positions struct {
+	calls    map[fp.Pos][]fp.Pos
+	toplevel []fp.Pos
+}
+
+// newPositions : follow the parser on the tokens, `x ,x and ,@x are calls of one argument and () is skipped
+func newPositions(tokens []fp.Token, posList []fp.Pos) positions {
+	p := positions{calls: make(map[fp.Pos][]fp.Pos)}
+	type open struct {
+		pos    fp.Pos
+		prefix bool // closed after its only argument
+		elems  []fp.Pos
+	}
+	var stack []*open
+	// add : element of the innermost call, then close the prefixes it completes
+	var add func(pos fp.Pos)
+	add = func(pos fp.Pos) {
+		if len(stack) == 0 {
+			p.toplevel = append(p.toplevel, pos)
+			return
+		}
+		top := stack[len(stack)-1]
+		top.elems = append(top.elems, pos)
+		if top.prefix && len(top.elems) == 2 {
+			stack = stack[:len(stack)-1]
+			p.calls[top.pos] = top.elems
+			add(top.pos)
+		}
+	}
+	for i, token := range tokens {
+		pos := posList[i]
+		switch token {
+		case "(":
+			stack = append(stack, &open{pos: pos})
+		case ")":
+			if len(stack) == 0 {
+				return p
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(top.elems) > 0 {
+				p.calls[top.pos] = top.elems
+				add(top.pos)
+			}
+		case "`", ",", ",@":
+			stack = append(stack, &open{pos: pos, prefix: true, elems: []fp.Pos{pos}})
+		default:
+			add(pos)
+		}
+	}
+	return p
+}
+
+// arg : position of the i-th element of call, the position of the call if unknown
+func (p positions) arg(call fp.LambdaExpr, i int) fp.Pos {
+	if elems := p.calls[call.Pos]; i < len(elems) {
+		return elems[i]
+	}
+	return call.Pos
+}
@@ -0,0 +1,144 @@
+package repl
+
+import (
+	"context"
+	"fp/pkg/debugger"
+	"fp/pkg/fp"
+	"fp/pkg/typecheck"
+	"sort"
+	"strings"
+)
+
+// parseCommand : :name args...
+func parseCommand(input string) (name string, args string, ok bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, ":") {
+		return "", "", false
+	}
+	name, args, _ = strings.Cut(input[1:], " ")
+	return name, strings.TrimSpace(args), true
+}
+
+func (r *fpRepl) command(ctx context.Context, name string, args string) {
+	switch name {
+	case "break":
+		r.breakCommand(args)
+	case "clear":
+		if r.debugger == nil || !r.debugger.Clear(fp.String(args)) {
+			r.writeln("no breakpoint on %s", args)
+			return
+		}
+		r.writeln("breakpoint on %s removed", args)
+	case "step", "next", "out", "continue":
+		if r.running == nil {
+			r.writeln("not paused")
+			return
+		}
+		switch name {
+		case "step":
+			r.debugger.Step()
+		case "next":
+			r.debugger.Next()
+		case "out":
+			r.debugger.Out()
+		case "continue":
+			r.debugger.Continue()
+		}
+		r.wait()
+		r.evalPending(ctx)
+	case "locals":
+		if r.running == nil {
+			r.writeln("not paused")
+			return
+		}
+		r.writeFrame("", r.debugger.Locals())
+	case "bt":
+		if r.running == nil {
+			r.writeln("not paused")
+			return
+		}
+		for i, call := range r.debugger.Backtrace() {
+			r.writeln("#%d %s", i, call.Expr)
+			r.writeFrame("    ", call.Locals)
+		}
+	case "type":
+		r.typeCommand(ctx, args)
+	default:
+		r.writeln("unknown command :%s - :break f [condition], :clear f, :step, :next, :out, :continue, :locals, :bt, :type expr (expands macros)", name)
+	}
+}
+
+// breakCommand : list breakpoints, or add a breakpoint on a function with an optional condition
+func (r *fpRepl) breakCommand(args string) {
+	if args == "" {
+		if r.debugger == nil {
+			return
+		}
+		for _, bp := range r.debugger.Breakpoints() {
+			if bp.Condition != nil {
+				r.writeln("%s if %s (hit %d times)", bp.Name, bp.Condition, bp.Hits)
+			} else {
+				r.writeln("%s (hit %d times)", bp.Name, bp.Hits)
+			}
+		}
+		return
+	}
+	name, source, _ := strings.Cut(args, " ")
+	var condition fp.Expr
+	if source = strings.TrimSpace(source); source != "" {
+		p := &fp.Parser{}
+		var exprs []fp.Expr
+		for _, token := range fp.Tokenize(source) {
+			if expr := p.Input(token); expr != nil {
+				exprs = append(exprs, expr)
+			}
+		}
+		if len(exprs) != 1 || len(p.Buffer) != 0 {
+			r.writeln("condition must be one expression")
+			return
+		}
+		condition = exprs[0]
+	}
+	if r.debugger == nil {
+		r.debugger = debugger.New(r.runtime)
+	}
+	r.debugger.Break(fp.String(name), condition)
+	r.writeln("breakpoint on %s", name)
+}
+
+// typeCommand : inferred type of an expression, without evaluating it
+//
+// macros in it are expanded first like before an evaluation, which runs their bodies
+func (r *fpRepl) typeCommand(ctx context.Context, source string) {
+	exprs, err := fp.ParseSource(source)
+	if err != nil {
+		r.writeln(err.Error())
+		return
+	}
+	if len(exprs) != 1 {
+		r.writeln("type requires one expression")
+		return
+	}
+	expr, err := r.runtime.Expand(ctx, exprs[0])
+	if err != nil {
+		r.writeln(err.Error())
+		return
+	}
+	t, errs := r.types.TypeOf(expr)
+	for _, err := range errs {
+		r.writeln(err.Error())
+	}
+	r.writeln("%s : %s", source, typecheck.TypeString(t))
+}
+
+// writeFrame : variables sorted by name
+func (r *fpRepl) writeFrame(indent string, frame fp.Frame) {
+	var names []string
+	for name := range frame {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.writeln("%s%s = %v", indent, name, frame[fp.String(name)])
+	}
+}
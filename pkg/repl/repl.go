@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"fp/pkg/debugger"
 	"fp/pkg/fp"
+	"fp/pkg/typecheck"
 	"sort"
+	"sync"
 )
 
 type REPL interface {
@@ -14,12 +17,33 @@ type REPL interface {
 }
 
 type fpRepl struct {
-	runtime *fp.Runtime
-	parser  *fp.Parser
-	buffer  string
+	runtime  *fp.Runtime
+	parser   *fp.Parser
+	buffer   string
+	mtx      sync.Mutex         // buffer is also written by spawned tasks
+	debugger *debugger.Debugger // attached by the first :break
+	pending  []fp.Expr          // expressions of the input after the running one
+	running  chan result        // evaluation paused by the debugger
+	parent   context.Context    // of session
+	session  context.Context    // of the evaluations run with the debugger, see sessionContext
+	cancel   context.CancelFunc // interrupt the running evaluation and the tasks it spawned
+	types    *typecheck.Checker // names bound by the evaluated expressions, for :type
+}
+
+type result struct {
+	output fp.Object
+	err    error
 }
 
 func (r *fpRepl) ReplyInput(ctx context.Context, input string) (output string, executed bool) {
+	if name, args, ok := parseCommand(input); ok && len(r.parser.Buffer) == 0 {
+		r.command(ctx, name, args)
+		return r.flush(), true
+	}
+	if r.running != nil {
+		r.writeln("evaluation paused, use :step, :next, :out, :continue, :locals or :bt")
+		return r.flush(), true
+	}
 	tokenList := fp.Tokenize(input)
 	executed = false
 	if len(tokenList) == 0 {
@@ -29,51 +53,137 @@ func (r *fpRepl) ReplyInput(ctx context.Context, input string) (output string, e
 			expr := r.parser.Input(token)
 			if expr != nil {
 				executed = true
-
-				lastFrame := make(fp.Frame).Update(r.runtime.Stack[len(r.runtime.Stack)-1])
-				stackSize := len(r.runtime.Stack)
-				output, err := r.runtime.Step(ctx, expr)
-				if err != nil {
-					if errors.Is(err, fp.InterruptError) {
-						// reset stack size
-						r.runtime.Stack = r.runtime.Stack[:stackSize-1]
-						r.runtime.Stack = append(r.runtime.Stack, lastFrame)
-						r.writeln("interrupted - stack was recovered")
-					}
-					r.writeln(err.Error())
-					continue
-				}
-				r.write("%v\n", output)
+				r.pending = append(r.pending, expr)
 			}
 		}
 	}
+	r.evalPending(ctx)
 	return r.flush(), executed
 }
 
+// evalPending : evaluate pending expressions in order until one is paused by the debugger
+func (r *fpRepl) evalPending(ctx context.Context) {
+	for len(r.pending) > 0 && r.running == nil {
+		expr := r.pending[0]
+		r.pending = r.pending[1:]
+		expr, err := r.runtime.Expand(ctx, expr)
+		if err != nil {
+			r.writeln(err.Error())
+			continue
+		}
+		// only to update the types of the names bound by the line, for :type. the errors are not shown,
+		// the evaluation reports the problems it runs into, :type and fp check report every type error
+		_, _ = r.types.Infer(expr)
+		if r.debugger == nil {
+			output, err := r.runtime.Step(ctx, expr)
+			r.report(output, err)
+			continue
+		}
+		// the debugger pauses the evaluation, run it on another goroutine to keep reading commands
+		r.debugger.Reset()
+		done := make(chan result, 1)
+		stepCtx := r.sessionContext(ctx)
+		go func() {
+			output, err := r.runtime.Step(stepCtx, expr)
+			done <- result{output: output, err: err}
+		}()
+		r.running = done
+		r.wait()
+	}
+}
+
+// sessionContext : context of the evaluations run with the debugger, a new one once ctx changes or ClearBuffer cancelled it
+//
+// it is not cancelled when a line is done, so that tasks spawned by a line outlive it like without the debugger
+func (r *fpRepl) sessionContext(ctx context.Context) context.Context {
+	if r.session == nil || r.parent != ctx || r.session.Err() != nil {
+		if r.cancel != nil {
+			r.cancel()
+		}
+		r.parent = ctx
+		r.session, r.cancel = context.WithCancel(ctx)
+	}
+	return r.session
+}
+
+// wait : until the running evaluation is done or paused
+func (r *fpRepl) wait() {
+	select {
+	case res := <-r.running:
+		r.running = nil
+		r.report(res.output, res.err)
+	case p := <-r.debugger.Paused():
+		r.writeln("%s", p)
+	}
+}
+
+func (r *fpRepl) report(output fp.Object, err error) {
+	if err != nil {
+		// Step already unwound the stack
+		if errors.Is(err, fp.InterruptError) {
+			r.writeln("interrupted - stack was recovered")
+		}
+		r.writeln(err.Error())
+		return
+	}
+	r.write("%v\n", output)
+}
+
 func (r *fpRepl) ClearBuffer() (output string) {
 	r.parser.Clear()
+	r.pending = nil
+	if r.cancel != nil {
+		// a paused evaluation stops as soon as it is interrupted, like the tasks it spawned
+		r.cancel()
+		r.session, r.cancel = nil, nil
+	}
+	if r.running != nil {
+		res := <-r.running
+		r.running = nil
+		r.report(res.output, res.err)
+	}
 	r.writeln("(Control + C) to clear parser buffer, (Control + D) to exit")
 	return r.flush()
 }
 
 func (r *fpRepl) flush() (output string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 	output, r.buffer = r.buffer, ""
 	return output
 }
 
 func (r *fpRepl) write(format string, a ...interface{}) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 	r.buffer += fmt.Sprintf(format, a...)
 }
 func (r *fpRepl) writeln(format string, a ...interface{}) {
 	r.write(format+"\n", a...)
 }
 
+// outputWriter : io.Writer appending program output to the repl output
+type outputWriter struct {
+	r *fpRepl
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.r.mtx.Lock()
+	defer w.r.mtx.Unlock()
+	w.r.buffer += string(p)
+	return len(p), nil
+}
+
 func NewFP(runtime *fp.Runtime) (repl REPL, welcome string) {
 	r := &fpRepl{
 		runtime: runtime,
 		parser:  &fp.Parser{},
 		buffer:  "",
+		types:   typecheck.New(),
 	}
+	// program output (print, eprint, ...) is displayed as part of the repl output
+	runtime.Stdout = outputWriter{r: r}
+	runtime.Stderr = outputWriter{r: r}
 	r.writeln("welcome to fp repl! type function or module name for help")
 	r.write("loaded modules: ")
 	var funcNameList []string
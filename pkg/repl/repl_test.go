@@ -0,0 +1,29 @@
+package repl
+
+import (
+	"context"
+	"fp/pkg/fp"
+	"strings"
+	"testing"
+)
+
+// TestSpawnWithDebugger : a task spawned by a line outlives it when the debugger is attached
+func TestSpawnWithDebugger(t *testing.T) {
+	r, _ := NewFP(fp.NewStdRuntime())
+	ctx := context.Background()
+	lines := []struct {
+		input string
+		want  string
+	}{
+		{":break h", "breakpoint on h"},
+		{"(let g (lambda x (add x 1)))", "(lambda x (add x 1))"},
+		{"(let f (spawn g 1))", ""},
+		{"(await f)", "2"},
+	}
+	for _, line := range lines {
+		output, executed := r.ReplyInput(ctx, line.input)
+		if !executed || !strings.Contains(output, line.want) {
+			t.Fatalf("%s: got %q, want %q", line.input, output, line.want)
+		}
+	}
+}
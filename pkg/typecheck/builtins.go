@@ -0,0 +1,107 @@
+package typecheck
+
+// signatures : types of the builtins, a call must match one of them, an argument matching several gives an unknown result
+var signatures = map[string][]string{
+	"add":           {"Int... -> Int"},
+	"mul":           {"Int... -> Int"},
+	"sub":           {"Int Int -> Int"},
+	"div":           {"Int Int -> Int"},
+	"mod":           {"Int Int -> Int"},
+	"sign":          {"Int -> Int"},
+	"append":        {"(List a) a... -> List a", "(Stream a) a... -> List a"},
+	"slice":         {"(List a) Int Int -> List a", "(Stream a) Int Int -> List a"},
+	"peek":          {"(List a) Int -> a", "(Stream a) Int -> a", "(List a) Int Int Int... -> List a", "(Stream a) Int Int Int... -> List a", "Dict Any Any... -> Any"},
+	"len":           {"(List a) -> Int", "(Stream a) -> Int", "Dict -> Int"},
+	"map":           {"(List a) (a -> b) -> List b", "(Stream a) (a -> b) -> List b"},
+	"type":          {"a -> String", "a b c... -> List String"},
+	"stack":         {"-> List Dict"},
+	"print":         {"Any... -> Int"},
+	"println":       {"Any... -> Int"},
+	"eprint":        {"Any... -> Int"},
+	"read-line":     {"-> String"},
+	"time":          {"-> Int"},
+	"rand":          {"Int -> Int"},
+	"doom":          {"-> String"},
+	"kaboom":        {"-> Any"},
+	"raise":         {"a -> b"},
+	"gensym":        {"String? -> Expr"},
+	"expr-name":     {"Expr -> Expr"},
+	"expr-args":     {"Expr -> List Expr"},
+	"memo":          {"a Int? -> a"},
+	"memo-stats":    {"a -> Dict"},
+	"pure?":         {"a -> Int"},
+	"purity":        {"a -> String"},
+	"await":         {"(Future a) Int? -> a"},
+	"chan":          {"Int? -> Chan a"},
+	"send":          {"(Chan a) a -> a"},
+	"recv":          {"(Chan a) -> Option a"},
+	"close":         {"(Chan a) -> Chan a"},
+	"ok":            {"a -> Result a b"},
+	"err":           {"b -> Result a b"},
+	"some":          {"a -> Option a"},
+	"none":          {"-> Option a"},
+	"unwrap":        {"(Result a b) -> a", "(Option a) -> a"},
+	"unwrap-or":     {"(Result a b) a -> a", "(Option a) a -> a"},
+	"map-ok":        {"(Result a e) (a -> b) -> Result b e", "(Option a) (a -> b) -> Option b"},
+	"range":         {"Int Int Int? -> List Int"},
+	"range-stream":  {"Int Int Int? -> Stream Int"},
+	"iterate":       {"(a -> a) a -> Stream a"},
+	"repeat":        {"a Int? -> Stream a"},
+	"cycle":         {"(List a) -> Stream a", "(Stream a) -> Stream a"},
+	"stream-map":    {"(Stream a) (a -> b) -> Stream b", "(List a) (a -> b) -> Stream b"},
+	"stream-filter": {"(Stream a) (a -> Int) -> Stream a", "(List a) (a -> Int) -> Stream a"},
+	"take":          {"(Stream a) Int -> Stream a", "(List a) Int -> Stream a"},
+	"take-while":    {"(Stream a) (a -> Int) -> Stream a", "(List a) (a -> Int) -> Stream a"},
+	"to-list":       {"(Stream a) -> List a", "(List a) -> List a"},
+	"force":         {"(Promise a) -> a", "a -> a"},
+}
+
+// forms : builtins whose arguments are not simply evaluated, each one is inferred by Checker.form
+var forms = []string{
+	"let", "del", "lambda", "case", "with", "letrec", "tail", "list", "defmacro", "quote", "quasiquote",
+	"macroexpand", "try", "select", "spawn", "try-call", "delay",
+}
+
+// bounds : number of arguments of a form, max is -1 without limit
+type bounds struct {
+	min, max int
+}
+
+// formArities : arguments of the forms, checked like the runtime does before binding or evaluating anything
+var formArities = map[string]bounds{
+	"let": {2, -1}, "del": {1, -1}, "lambda": {1, -1}, "case": {1, -1}, "with": {1, -1}, "letrec": {1, -1},
+	"tail": {1, -1}, "defmacro": {2, -1}, "quote": {1, 1}, "quasiquote": {1, 1}, "macroexpand": {1, 1},
+	"try": {1, -1}, "select": {1, -1}, "spawn": {1, -1}, "try-call": {1, -1}, "delay": {1, 1},
+}
+
+// overloaded : several signatures, the type of a builtin used as a value is Any
+type overloaded []*Func
+
+// form : builtin inferred by Checker.form, its type as a value is Any
+type form struct{}
+
+// macro : defined by defmacro, calls are not checked since the expansion is not known before running
+type macro struct{}
+
+func (overloaded) isType() {}
+func (form) isType()       {}
+func (macro) isType()      {}
+
+func builtins() map[string]Type {
+	names := make(map[string]Type)
+	for name, sigs := range signatures {
+		if len(sigs) == 1 {
+			names[name] = parseSig(sigs[0])
+			continue
+		}
+		var alternatives overloaded
+		for _, sig := range sigs {
+			alternatives = append(alternatives, parseSig(sig))
+		}
+		names[name] = alternatives
+	}
+	for _, name := range forms {
+		names[name] = form{}
+	}
+	return names
+}
@@ -0,0 +1,341 @@
+package typecheck
+
+import (
+	"fmt"
+	"fp/pkg/fp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error : type error, at the position of the call if the expression was parsed with positions
+type Error struct {
+	Pos     fp.Pos
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Pos.Line == 0 {
+		return "type error: " + e.Message
+	}
+	return fmt.Sprintf("%s: type error: %s", e.Pos, e.Message)
+}
+
+// scope : types of the names of a frame, the outermost one has the builtins
+type scope struct {
+	names  map[string]Type
+	parent *scope
+}
+
+func (s *scope) lookup(name string) (Type, bool) {
+	for ; s != nil; s = s.parent {
+		if t, ok := s.names[name]; ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Checker : Hindley-Milner inference with let polymorphism, names unknown to the checker are Any
+//
+// the global scope is kept between expressions like the global frame of a runtime
+type Checker struct {
+	global  *scope
+	level   int
+	trail   []change
+	errors  []*Error
+	defined map[string]bool // names bound anywhere in the source given to Check, calls of other unknown names are errors
+}
+
+func New() *Checker {
+	return &Checker{
+		global: &scope{names: make(map[string]Type), parent: &scope{names: builtins()}},
+	}
+}
+
+// Infer : type of expr, names bound by let at top level are kept for the next expressions
+func (c *Checker) Infer(expr fp.Expr) (Type, []*Error) {
+	return c.run(c.global, expr)
+}
+
+// TypeOf : type of expr, without keeping the names it binds
+func (c *Checker) TypeOf(expr fp.Expr) (Type, []*Error) {
+	return c.run(&scope{names: make(map[string]Type), parent: c.global}, expr)
+}
+
+func (c *Checker) run(s *scope, expr fp.Expr) (Type, []*Error) {
+	c.errors, c.trail = nil, nil
+	c.level++
+	t := c.infer(s, expr)
+	c.level--
+	c.generalize(t)
+	c.trail = nil
+	return t, c.errors
+}
+
+func (c *Checker) report(pos fp.Pos, format string, args ...any) {
+	c.errors = append(c.errors, &Error{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// show : types of one message, variables with the same name are the same
+func show(types ...Type) []any {
+	n := namer{}
+	var out []any
+	for _, t := range types {
+		out = append(out, n.str(t, false))
+	}
+	return out
+}
+
+// Def : type of a let at top level
+type Def struct {
+	Name string
+	Pos  fp.Pos
+	Type Type
+}
+
+// Check : types of the top level lets of source and its type errors sorted by position, err if it does not parse
+func Check(source string) ([]Def, []*Error, error) {
+	exprs, err := fp.ParseSource(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := New()
+	c.defined = make(map[string]bool)
+	var collect func(exprs []fp.Expr)
+	collect = func(exprs []fp.Expr) {
+		for _, expr := range exprs {
+			if e, ok := expr.(fp.LambdaExpr); ok {
+				if name, ok := bindingName(e); ok && (e.Name == "let" || e.Name == "defmacro") {
+					c.defined[name] = true
+				}
+				collect(e.Args)
+			}
+		}
+	}
+	collect(exprs)
+	var defs []Def
+	var errs []*Error
+	for _, expr := range exprs {
+		t, exprErrs := c.Infer(expr)
+		errs = append(errs, exprErrs...)
+		if e, ok := expr.(fp.LambdaExpr); ok && e.Name == "let" && len(e.Args) >= 2 {
+			if name, ok := bindingName(e); ok {
+				defs = append(defs, Def{Name: name, Pos: e.Pos, Type: t})
+			}
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Pos.Line != errs[j].Pos.Line {
+			return errs[i].Pos.Line < errs[j].Pos.Line
+		}
+		return errs[i].Pos.Col < errs[j].Pos.Col
+	})
+	return defs, errs, nil
+}
+
+// infer : type of expr in scope s
+func (c *Checker) infer(s *scope, expr fp.Expr) Type {
+	switch e := expr.(type) {
+	case fp.NameExpr:
+		return c.name(s, string(e))
+	case fp.LambdaExpr:
+		t, _ := s.lookup(string(e.Name))
+		switch f := t.(type) {
+		case form:
+			if !c.formArity(e) {
+				c.args(s, e)
+				return Any
+			}
+			return c.form(s, e)
+		case macro:
+			return Any
+		case nil:
+			// unknown to the checker, the arguments are still checked
+			if c.defined != nil && !c.defined[string(e.Name)] {
+				c.report(e.Pos, "%s is not defined", e.Name)
+			}
+			c.args(s, e)
+			return Any
+		default:
+			if f, ok := f.(overloaded); ok {
+				return c.overloaded(s, e, f)
+			}
+			return c.call(s, e, c.instantiate(f))
+		}
+	}
+	return Any
+}
+
+func (c *Checker) name(s *scope, name string) Type {
+	if _, err := strconv.Atoi(name); err == nil {
+		return Int
+	}
+	switch {
+	case strings.HasPrefix(name, `"`):
+		return String
+	case name == "_":
+		// wildcard, matches every value in case
+		return c.newVar()
+	}
+	t, ok := s.lookup(name)
+	if !ok {
+		return Any
+	}
+	switch t.(type) {
+	case overloaded, form, macro:
+		return Any
+	}
+	return c.instantiate(t)
+}
+
+// args : types of the arguments of a call, ok is false if * or a keyword make the arguments only known at runtime
+func (c *Checker) args(s *scope, e fp.LambdaExpr) (types []Type, ok bool) {
+	ok = true
+	for _, arg := range e.Args {
+		if name, isName := arg.(fp.NameExpr); isName && (name == "*" || len(name) > 1 && name[0] == ':') {
+			ok = false
+			continue
+		}
+		types = append(types, c.infer(s, arg))
+	}
+	return types, ok
+}
+
+// call : result of calling f with the arguments of e
+func (c *Checker) call(s *scope, e fp.LambdaExpr, f Type) Type {
+	args, ok := c.args(s, e)
+	if !ok {
+		if f, isFunc := resolve(f).(*Func); isFunc {
+			return f.Ret
+		}
+		return c.newVar()
+	}
+	return c.apply(e.Pos, string(e.Name), f, args)
+}
+
+func (c *Checker) apply(pos fp.Pos, name string, f Type, args []Type) Type {
+	switch f := resolve(f).(type) {
+	case *Func:
+		if len(args) < f.Min || f.Rest == nil && len(args) > len(f.Params) {
+			c.report(pos, "%s requires %s, got %d", name, arity(f), len(args))
+			return f.Ret
+		}
+		for i, arg := range args {
+			p := param(f, i)
+			if c.unify(p, arg) {
+				continue
+			}
+			if _, ok := resolve(p).(*Var); ok {
+				// the variable occurs in the argument, like x in (x x)
+				c.report(pos, "%s: argument %d has the infinite type %s = %s", append([]any{name, i + 1}, show(p, arg)...)...)
+				continue
+			}
+			c.report(pos, "%s: argument %d must be %s, got %s", append([]any{name, i + 1}, show(p, arg)...)...)
+		}
+		return f.Ret
+	case *Var:
+		// a parameter called as a function
+		g := &Func{Min: len(args), Ret: c.newVar()}
+		for range args {
+			g.Params = append(g.Params, c.newVar())
+		}
+		c.unify(f, g)
+		return c.apply(pos, name, g, args)
+	default:
+		if !isAny(f) {
+			c.report(pos, "%s is %s, not a function", name, TypeString(f))
+		}
+		return c.newVar()
+	}
+}
+
+// arity : number of arguments of f, like the errors of the runtime
+func arity(f *Func) string {
+	if f.Rest != nil {
+		return count(bounds{f.Min, -1})
+	}
+	return count(bounds{f.Min, len(f.Params)})
+}
+
+func count(b bounds) string {
+	switch {
+	case b.max < 0 && b.min == 1:
+		return "at least 1 argument"
+	case b.max < 0:
+		return fmt.Sprintf("at least %d arguments", b.min)
+	case b.max == 1 && b.min == 1:
+		return "1 argument"
+	case b.min == b.max:
+		return fmt.Sprintf("%d arguments", b.min)
+	default:
+		return fmt.Sprintf("%d to %d arguments", b.min, b.max)
+	}
+}
+
+// formArity : false if the form has a wrong number of arguments, calls with * or keywords are not checked
+func (c *Checker) formArity(e fp.LambdaExpr) bool {
+	b, ok := formArities[string(e.Name)]
+	if !ok {
+		return true
+	}
+	for _, arg := range e.Args {
+		if name, ok := arg.(fp.NameExpr); ok && (name == "*" || len(name) > 1 && name[0] == ':') {
+			return true
+		}
+	}
+	if n := len(e.Args); n < b.min || b.max >= 0 && n > b.max {
+		c.report(e.Pos, "%s requires %s, got %d", e.Name, count(b), n)
+		return false
+	}
+	return true
+}
+
+// overloaded : the first signature the arguments match, an unknown result if they match several
+// only because some arguments are not known yet, like (len x) in a lambda
+func (c *Checker) overloaded(s *scope, e fp.LambdaExpr, alternatives overloaded) Type {
+	args, ok := c.args(s, e)
+	if !ok {
+		return c.newVar()
+	}
+	var matches []*Func
+	for _, alternative := range alternatives {
+		mark, errs := len(c.trail), len(c.errors)
+		f := c.instantiate(alternative).(*Func)
+		c.apply(e.Pos, string(e.Name), f, args)
+		if len(c.errors) == errs {
+			matches = append(matches, alternative)
+		}
+		c.errors = c.errors[:errs]
+		c.undo(mark)
+	}
+	switch len(matches) {
+	case 0:
+		var sigs []string
+		for _, alternative := range alternatives {
+			sigs = append(sigs, TypeString(alternative))
+		}
+		c.report(e.Pos, "%s: no signature matches %s, expected %s", e.Name, argsString(args), strings.Join(sigs, " or "))
+		return c.newVar()
+	case 1:
+	default:
+		for _, arg := range args {
+			if _, ok := resolve(arg).(*Var); ok {
+				return c.newVar()
+			}
+		}
+	}
+	return c.apply(e.Pos, string(e.Name), c.instantiate(matches[0]), args)
+}
+
+func argsString(args []Type) string {
+	if len(args) == 0 {
+		return "no argument"
+	}
+	n := namer{}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = n.str(arg, true)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,50 @@
+package typecheck
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		source string
+		defs   string // name : type of every let, one per line
+		errors string // messages, one per line
+	}{
+		{`(let x 4) (let y (add x 1))`, "x : Int\ny : Int", ""},
+		{`(let f (lambda x (g x))) (let g (lambda x x))`, "f : a -> Any\ng : a -> a", ""},
+		{`(let 3 4)`, "", "let: argument 1 must be a name, got 3"},
+		{`(let "s" 4)`, "", `let: argument 1 must be a name, got "s"`},
+		{`(let)`, "", "let requires at least 2 arguments, got 0"},
+		{`(del 3)`, "", "del: argument 1 must be a name, got 3"},
+		{`(case)`, "", "case requires at least 1 argument, got 0"},
+		{`(case 1 2)`, "", "case: pattern 2 has no result"},
+		{`(with (3 4) 1)`, "", "with: binding must be of the form (name expr), got (3 4)"},
+		{`(letrec x 1)`, "", "letrec: binding must be of the form (name expr), got x"},
+		{`(quote)`, "", "quote requires 1 argument, got 0"},
+		{`(lambda 1 2 (add 1 2))`, "", "lambda: argument 1 must be a name, got 1\nlambda: argument 2 must be a name, got 2"},
+		{`(cons 1)`, "", "cons is not defined"},
+		{`(sub 1)`, "", "sub requires 2 arguments, got 1"},
+		{`(let l (list 1 2)) (tail * l)`, "l : List Int", ""},
+	}
+	for _, test := range tests {
+		defs, errs, err := Check(test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		var gotDefs, gotErrs []string
+		for _, def := range defs {
+			gotDefs = append(gotDefs, def.Name+" : "+TypeString(def.Type))
+		}
+		for _, e := range errs {
+			gotErrs = append(gotErrs, e.Message)
+		}
+		if got := strings.Join(gotDefs, "\n"); got != test.defs {
+			t.Errorf("%s: defs %q, want %q", test.source, got, test.defs)
+		}
+		if got := strings.Join(gotErrs, "\n"); got != test.errors {
+			t.Errorf("%s: errors %q, want %q", test.source, got, test.errors)
+		}
+	}
+}
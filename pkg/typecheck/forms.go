@@ -0,0 +1,329 @@
+package typecheck
+
+import (
+	"fmt"
+	"fp/pkg/fp"
+	"strconv"
+	"strings"
+)
+
+// form : builtins that bind names or do not evaluate every argument
+func (c *Checker) form(s *scope, e fp.LambdaExpr) Type {
+	switch e.Name {
+	case "let":
+		name, ok := c.binding(e)
+		if !ok {
+			break
+		}
+		for _, arg := range e.Args[1 : len(e.Args)-1] {
+			c.infer(s, arg)
+		}
+		return c.define(s, e, name, e.Args[len(e.Args)-1])
+	case "del":
+		if name, ok := c.binding(e); ok {
+			delete(s.names, name)
+		}
+		return Any
+	case "lambda":
+		return c.lambda(s, e)
+	case "case":
+		if len(e.Args)%2 == 0 {
+			c.report(e.Pos, "case: pattern %s has no result", e.Args[len(e.Args)-1])
+		}
+		return c.caseForm(s, e)
+	case "with", "letrec":
+		return c.bindings(s, e)
+	case "tail":
+		types, ok := c.args(s, e)
+		if !ok || len(types) == 0 {
+			return c.newVar()
+		}
+		return types[len(types)-1]
+	case "list":
+		types, ok := c.args(s, e)
+		if !ok {
+			return list(c.newVar())
+		}
+		// lists may mix types, their elements are then Any
+		elem, mark := c.newVar(), len(c.trail)
+		for _, t := range types {
+			if !c.unify(elem, t) {
+				c.undo(mark)
+				return list(Any)
+			}
+		}
+		return list(elem)
+	case "defmacro":
+		if name, ok := c.binding(e); ok {
+			s.names[name] = macro{}
+		}
+		return Con{Name: "Macro"}
+	case "quote", "quasiquote", "macroexpand":
+		return Expr
+	case "try":
+		return c.try(s, e)
+	case "select":
+		return c.selectForm(s, e)
+	case "spawn", "try-call":
+		if len(e.Args) == 0 {
+			break
+		}
+		types, ok := c.args(s, e)
+		ret := Type(c.newVar())
+		if ok {
+			ret = c.apply(e.Pos, fmt.Sprint(e.Args[0]), types[0], types[1:])
+		}
+		if e.Name == "spawn" {
+			return Con{Name: "Future", Args: []Type{ret}}
+		}
+		return Con{Name: "Result", Args: []Type{ret, Any}}
+	case "delay":
+		if len(e.Args) != 1 {
+			break
+		}
+		return Con{Name: "Promise", Args: []Type{c.infer(s, e.Args[0])}}
+	}
+	// invalid arguments are reported by the runtime, their types are still checked
+	c.args(s, e)
+	return Any
+}
+
+// bindingName : first argument of let, del or defmacro, literals like 3 or "s" are not names
+func bindingName(e fp.LambdaExpr) (string, bool) {
+	if len(e.Args) == 0 {
+		return "", false
+	}
+	name, ok := e.Args[0].(fp.NameExpr)
+	if !ok || literal(string(name)) {
+		return "", false
+	}
+	return string(name), true
+}
+
+// literal : names evaluated without lookup, and keywords of calls
+func literal(name string) bool {
+	if _, err := strconv.Atoi(name); err == nil {
+		return true
+	}
+	return name == "_" || name == "*" || strings.HasPrefix(name, `"`) || strings.HasPrefix(name, ":")
+}
+
+// binding : bindingName, reported if the first argument is not a name
+func (c *Checker) binding(e fp.LambdaExpr) (string, bool) {
+	name, ok := bindingName(e)
+	if !ok && len(e.Args) > 0 {
+		c.report(e.Pos, "%s: argument 1 must be a name, got %s", e.Name, e.Args[0])
+	}
+	return name, ok
+}
+
+// define : name bound to the type of expr in s, generalized if expr is a lambda
+//
+// the name is visible in the lambda for recursive calls, since its body runs once the let is done
+func (c *Checker) define(s *scope, e fp.LambdaExpr, name string, expr fp.Expr) Type {
+	value, isLambda := expr.(fp.LambdaExpr)
+	isLambda = isLambda && value.Name == "lambda"
+	c.level++
+	var self *Var
+	if isLambda {
+		self = c.newVar()
+		s.names[name] = self
+	}
+	t := c.infer(s, expr)
+	if self != nil && !c.unify(self, t) {
+		c.report(e.Pos, "%s is used as %s in its body, defined as %s", append([]any{name}, show(self, t)...)...)
+	}
+	c.level--
+	if isLambda {
+		c.generalize(t)
+	} else {
+		c.lower(t)
+	}
+	s.names[name] = t
+	return c.instantiate(t)
+}
+
+// lambda : (lambda x (y default) * rest body)
+func (c *Checker) lambda(s *scope, e fp.LambdaExpr) Type {
+	if len(e.Args) == 0 {
+		return Any
+	}
+	inner := &scope{names: make(map[string]Type), parent: s}
+	f := &Func{}
+	params := e.Args[:len(e.Args)-1]
+	for i := 0; i < len(params); i++ {
+		switch p := params[i].(type) {
+		case fp.NameExpr:
+			if p == "*" {
+				if i+1 < len(params) {
+					if name, ok := params[i+1].(fp.NameExpr); ok {
+						f.Rest = c.newVar()
+						inner.names[string(name)] = list(f.Rest)
+					}
+				}
+				i++
+				continue
+			}
+			v := c.newVar()
+			if literal(string(p)) {
+				c.report(e.Pos, "lambda: argument %d must be a name, got %s", i+1, p)
+			} else {
+				inner.names[string(p)] = v
+			}
+			f.Params = append(f.Params, v)
+			if f.Min == len(f.Params)-1 {
+				f.Min++
+			}
+		case fp.LambdaExpr:
+			v := c.newVar()
+			if len(p.Args) == 1 {
+				if d := c.infer(inner, p.Args[0]); !c.unify(v, d) {
+					c.report(e.Pos, "default of %s must be %s, got %s", append([]any{p.Name}, show(v, d)...)...)
+				}
+			}
+			if literal(string(p.Name)) {
+				c.report(e.Pos, "lambda: argument %d must be a name, got %s", i+1, p.Name)
+			} else {
+				inner.names[string(p.Name)] = v
+			}
+			f.Params = append(f.Params, v)
+		}
+	}
+	f.Ret = c.infer(inner, e.Args[len(e.Args)-1])
+	return f
+}
+
+// caseForm : patterns have the type of the value, results have the same type
+func (c *Checker) caseForm(s *scope, e fp.LambdaExpr) Type {
+	if len(e.Args) == 0 {
+		return Any
+	}
+	value := c.infer(s, e.Args[0])
+	result := c.newVar()
+	for i := 1; i < len(e.Args); i += 2 {
+		if p := c.infer(s, e.Args[i]); !c.unify(value, p) {
+			c.report(e.Pos, "case: pattern %d must be %s, got %s", append([]any{(i + 1) / 2}, show(value, p)...)...)
+		}
+		if i+1 < len(e.Args) {
+			if r := c.infer(s, e.Args[i+1]); !c.unify(result, r) {
+				c.report(e.Pos, "case: result %d must be %s, got %s", append([]any{(i + 1) / 2}, show(result, r)...)...)
+			}
+		}
+	}
+	return result
+}
+
+// bindings : (with (x expr) ... body) binds one after another, (letrec (f expr) ... body) every name first
+func (c *Checker) bindings(s *scope, e fp.LambdaExpr) Type {
+	if len(e.Args) == 0 {
+		return Any
+	}
+	inner := &scope{names: make(map[string]Type), parent: s}
+	var bindings []fp.LambdaExpr
+	for _, arg := range e.Args[:len(e.Args)-1] {
+		b, ok := arg.(fp.LambdaExpr)
+		if !ok || len(b.Args) != 1 || literal(string(b.Name)) {
+			c.report(e.Pos, "%s: binding must be of the form (name expr), got %s", e.Name, arg)
+			continue
+		}
+		bindings = append(bindings, b)
+	}
+	if e.Name == "with" {
+		for _, b := range bindings {
+			c.define(inner, e, string(b.Name), b.Args[0])
+		}
+		return c.infer(inner, e.Args[len(e.Args)-1])
+	}
+	c.level++
+	vars := make([]*Var, len(bindings))
+	for i, b := range bindings {
+		vars[i] = c.newVar()
+		inner.names[string(b.Name)] = vars[i]
+	}
+	for i, b := range bindings {
+		if t := c.infer(inner, b.Args[0]); !c.unify(vars[i], t) {
+			c.report(e.Pos, "letrec: %s is used as %s, defined as %s", append([]any{b.Name}, show(vars[i], t)...)...)
+		}
+	}
+	c.level--
+	for _, v := range vars {
+		c.generalize(v)
+	}
+	return c.infer(inner, e.Args[len(e.Args)-1])
+}
+
+// try : the body and the handler of catch have the same type, the error e is Any
+func (c *Checker) try(s *scope, e fp.LambdaExpr) Type {
+	var body, handler Type
+	for _, arg := range e.Args {
+		clause, _ := arg.(fp.LambdaExpr)
+		switch {
+		case clause.Name == "catch" && len(clause.Args) >= 2:
+			inner := &scope{names: make(map[string]Type), parent: s}
+			if name, ok := clause.Args[0].(fp.NameExpr); ok {
+				inner.names[string(name)] = Any
+			}
+			for _, expr := range clause.Args[1:] {
+				handler = c.infer(inner, expr)
+			}
+		case clause.Name == "finally":
+			c.args(s, clause)
+		default:
+			body = c.infer(s, arg)
+		}
+	}
+	if body == nil {
+		return Any
+	}
+	if handler != nil && !c.unify(body, handler) {
+		c.report(e.Pos, "try: catch must be %s, got %s", show(body, handler)...)
+	}
+	return body
+}
+
+// selectForm : (select (recv c x ...) (send c v ...) (timeout ms ...) (default ...)), clauses have the same type
+func (c *Checker) selectForm(s *scope, e fp.LambdaExpr) Type {
+	result := c.newVar()
+	for i, arg := range e.Args {
+		clause, ok := arg.(fp.LambdaExpr)
+		if !ok {
+			continue
+		}
+		inner := &scope{names: make(map[string]Type), parent: s}
+		var body []fp.Expr
+		switch clause.Name {
+		case "recv", "send":
+			if len(clause.Args) < 2 {
+				continue
+			}
+			elem := c.newVar()
+			if ch := c.infer(s, clause.Args[0]); !c.unify(ch, Con{Name: "Chan", Args: []Type{elem}}) {
+				c.report(e.Pos, "select: %s requires a channel, got %s", clause.Name, TypeString(ch))
+			}
+			if name, ok := clause.Args[1].(fp.NameExpr); ok && clause.Name == "recv" {
+				inner.names[string(name)] = Con{Name: "Option", Args: []Type{elem}}
+			} else if v := c.infer(s, clause.Args[1]); !c.unify(elem, v) {
+				c.report(e.Pos, "select: send of %s on a channel of %s", show(v, elem)...)
+			}
+			body = clause.Args[2:]
+		case "timeout":
+			if len(clause.Args) < 1 {
+				continue
+			}
+			if ms := c.infer(s, clause.Args[0]); !c.unify(Int, ms) {
+				c.report(e.Pos, "select: timeout must be Int, got %s", TypeString(ms))
+			}
+			body = clause.Args[1:]
+		case "default":
+			body = clause.Args
+		}
+		var t Type
+		for _, expr := range body {
+			t = c.infer(inner, expr)
+		}
+		if t != nil && !c.unify(result, t) {
+			c.report(e.Pos, "select: clause %d must be %s, got %s", append([]any{i + 1}, show(result, t)...)...)
+		}
+	}
+	return result
+}
@@ -0,0 +1,205 @@
+package typecheck
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Type : *Var, Con or *Func
+type Type interface {
+	isType()
+}
+
+// generic : level of the variables of a type scheme, replaced by fresh variables at every use
+const generic = 1 << 30
+
+// Var : type variable, ref is the type it was unified with
+type Var struct {
+	level int // of the let introducing it, variables deeper than the let are generalized
+	ref   Type
+}
+
+// Con : type constructor and its arguments, like Int or List Int
+type Con struct {
+	Name string
+	Args []Type
+}
+
+// Func : function of len(Params) arguments, the first Min are required, the next ones are optional
+// and Rest is the type of the remaining arguments if it is variadic
+type Func struct {
+	Params []Type
+	Min    int
+	Rest   Type
+	Ret    Type
+}
+
+func (*Var) isType()  {}
+func (Con) isType()   {}
+func (*Func) isType() {}
+
+var (
+	// Any : dynamic type, unifies with every type, for names the checker does not know
+	Any    = Con{Name: "Any"}
+	Int    = Con{Name: "Int"}
+	String = Con{Name: "String"}
+	Dict   = Con{Name: "Dict"}
+	Expr   = Con{Name: "Expr"}
+)
+
+func list(t Type) Con {
+	return Con{Name: "List", Args: []Type{t}}
+}
+
+// resolve : follow the variables already unified
+func resolve(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.ref == nil {
+			return t
+		}
+		t = v.ref
+	}
+}
+
+func isAny(t Type) bool {
+	c, ok := t.(Con)
+	return ok && c.Name == Any.Name
+}
+
+// TypeString : the type with its variables named a, b, c ... in order of appearance
+func TypeString(t Type) string {
+	return namer{}.str(t, false)
+}
+
+// namer : names of the variables printed so far, shared by the types of one message
+type namer map[*Var]string
+
+func (n namer) str(t Type, atom bool) string {
+	var s string
+	switch t := resolve(t).(type) {
+	case *Var:
+		name, ok := n[t]
+		if !ok {
+			name = string(rune('a' + len(n)%26))
+			if len(n) >= 26 {
+				name += strconv.Itoa(len(n) / 26)
+			}
+			n[t] = name
+		}
+		return name
+	case Con:
+		if len(t.Args) == 0 {
+			return t.Name
+		}
+		parts := []string{t.Name}
+		for _, arg := range t.Args {
+			parts = append(parts, n.str(arg, true))
+		}
+		s = strings.Join(parts, " ")
+	case *Func:
+		var parts []string
+		for i, p := range t.Params {
+			part := n.str(p, true)
+			if i >= t.Min {
+				part += "?"
+			}
+			parts = append(parts, part)
+		}
+		if t.Rest != nil {
+			parts = append(parts, n.str(t.Rest, true)+"...")
+		}
+		_, retFunc := resolve(t.Ret).(*Func)
+		parts = append(parts, "->", n.str(t.Ret, retFunc))
+		s = strings.Join(parts, " ")
+	default:
+		return "?"
+	}
+	if atom {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// parseSig : signature of a builtin like (List a) (a -> b) -> List b, a? is optional and a... variadic,
+// lowercase names are the variables of the scheme
+func parseSig(sig string) *Func {
+	for _, sep := range []string{"(", ")", "?", "..."} {
+		sig = strings.ReplaceAll(sig, sep, " "+sep+" ")
+	}
+	p := &sigParser{tokens: strings.Fields(sig), vars: make(map[string]*Var)}
+	f, ok := p.seq().(*Func)
+	if !ok || p.i != len(p.tokens) {
+		panic("invalid signature " + sig)
+	}
+	return f
+}
+
+type sigParser struct {
+	tokens []string
+	i      int
+	vars   map[string]*Var
+}
+
+func (p *sigParser) peek() string {
+	if p.i < len(p.tokens) {
+		return p.tokens[p.i]
+	}
+	return ""
+}
+
+func (p *sigParser) next() string {
+	token := p.peek()
+	p.i++
+	return token
+}
+
+// seq : parameters -> result, or a constructor applied to its arguments, until ) or the end
+func (p *sigParser) seq() Type {
+	f := &Func{}
+	var atoms []Type
+	for token := p.peek(); token != ")" && token != "->" && token != ""; token = p.peek() {
+		atom := p.atom()
+		switch p.peek() {
+		case "?":
+			p.next()
+			f.Params = append(f.Params, atom)
+		case "...":
+			p.next()
+			f.Rest = atom
+		default:
+			f.Params = append(f.Params, atom)
+			f.Min = len(f.Params)
+		}
+		atoms = append(atoms, atom)
+	}
+	if p.peek() != "->" {
+		c, ok := atoms[0].(Con)
+		if !ok || len(atoms) == 1 {
+			return atoms[0]
+		}
+		return Con{Name: c.Name, Args: atoms[1:]}
+	}
+	p.next()
+	f.Ret = p.seq()
+	return f
+}
+
+func (p *sigParser) atom() Type {
+	token := p.next()
+	switch {
+	case token == "(":
+		t := p.seq()
+		p.next() // )
+		return t
+	case token[0] >= 'a' && token[0] <= 'z':
+		v, ok := p.vars[token]
+		if !ok {
+			v = &Var{level: generic}
+			p.vars[token] = v
+		}
+		return v
+	default:
+		return Con{Name: token}
+	}
+}
@@ -0,0 +1,205 @@
+package typecheck
+
+// change : previous state of a variable, restored by undo
+type change struct {
+	v     *Var
+	ref   Type
+	level int
+}
+
+func (c *Checker) newVar() *Var {
+	return &Var{level: c.level}
+}
+
+func (c *Checker) set(v *Var, ref Type, level int) {
+	c.trail = append(c.trail, change{v: v, ref: v.ref, level: v.level})
+	v.ref, v.level = ref, level
+}
+
+// undo : every unification since the trail had length mark, to try a signature of a builtin
+func (c *Checker) undo(mark int) {
+	for len(c.trail) > mark {
+		ch := c.trail[len(c.trail)-1]
+		c.trail = c.trail[:len(c.trail)-1]
+		ch.v.ref, ch.v.level = ch.ref, ch.level
+	}
+}
+
+// unify : make a and b equal by binding their variables, false if they cannot be
+func (c *Checker) unify(a Type, b Type) bool {
+	a, b = resolve(a), resolve(b)
+	if isAny(a) || isAny(b) {
+		return true
+	}
+	if v, ok := a.(*Var); ok {
+		return c.bind(v, b)
+	}
+	if v, ok := b.(*Var); ok {
+		return c.bind(v, a)
+	}
+	switch a := a.(type) {
+	case Con:
+		b, ok := b.(Con)
+		if !ok || a.Name != b.Name || len(a.Args) != len(b.Args) {
+			return false
+		}
+		for i := range a.Args {
+			if !c.unify(a.Args[i], b.Args[i]) {
+				return false
+			}
+		}
+		return true
+	case *Func:
+		b, ok := b.(*Func)
+		return ok && c.unifyFunc(a, b)
+	}
+	return false
+}
+
+// param : type of the i-th argument of f, nil if f does not take it
+func param(f *Func, i int) Type {
+	if i < len(f.Params) {
+		return f.Params[i]
+	}
+	return f.Rest
+}
+
+// unifyFunc : functions accepting a common number of arguments, with the same argument and result types
+func (c *Checker) unifyFunc(a *Func, b *Func) bool {
+	maxArgs := func(f *Func) int {
+		if f.Rest != nil {
+			return generic
+		}
+		return len(f.Params)
+	}
+	if max(a.Min, b.Min) > min(maxArgs(a), maxArgs(b)) {
+		return false
+	}
+	for i := 0; i < max(len(a.Params), len(b.Params)); i++ {
+		pa, pb := param(a, i), param(b, i)
+		if pa != nil && pb != nil && !c.unify(pa, pb) {
+			return false
+		}
+	}
+	if a.Rest != nil && b.Rest != nil && !c.unify(a.Rest, b.Rest) {
+		return false
+	}
+	return c.unify(a.Ret, b.Ret)
+}
+
+// bind : v becomes t, the variables of t move to the level of v so they are generalized with it
+func (c *Checker) bind(v *Var, t Type) bool {
+	if w, ok := t.(*Var); ok && w == v {
+		return true
+	}
+	var occurs func(t Type) bool
+	occurs = func(t Type) bool {
+		switch t := resolve(t).(type) {
+		case *Var:
+			if t == v {
+				return true
+			}
+			if t.level > v.level {
+				c.set(t, nil, v.level)
+			}
+		case Con:
+			for _, arg := range t.Args {
+				if occurs(arg) {
+					return true
+				}
+			}
+		case *Func:
+			for _, p := range t.Params {
+				if occurs(p) {
+					return true
+				}
+			}
+			return t.Rest != nil && occurs(t.Rest) || occurs(t.Ret)
+		}
+		return false
+	}
+	if occurs(t) {
+		// infinite type like a = List a
+		return false
+	}
+	c.set(v, t, v.level)
+	return true
+}
+
+// generalize : variables created deeper than the current level become the variables of a scheme
+func (c *Checker) generalize(t Type) {
+	walk(t, func(v *Var) {
+		if v.level > c.level && v.level != generic {
+			v.level = generic
+		}
+	})
+}
+
+// lower : variables deeper than the current level stay monomorphic, like the ones of a let that is not a lambda
+func (c *Checker) lower(t Type) {
+	walk(t, func(v *Var) {
+		if v.level > c.level {
+			v.level = c.level
+		}
+	})
+}
+
+// walk : every variable of t that is not bound
+func walk(t Type, f func(v *Var)) {
+	switch t := resolve(t).(type) {
+	case *Var:
+		f(t)
+	case Con:
+		for _, arg := range t.Args {
+			walk(arg, f)
+		}
+	case *Func:
+		for _, p := range t.Params {
+			walk(p, f)
+		}
+		if t.Rest != nil {
+			walk(t.Rest, f)
+		}
+		walk(t.Ret, f)
+	}
+}
+
+// instantiate : copy of a scheme with fresh variables
+func (c *Checker) instantiate(t Type) Type {
+	fresh := make(map[*Var]*Var)
+	var copyType func(t Type) Type
+	copyType = func(t Type) Type {
+		switch t := resolve(t).(type) {
+		case *Var:
+			if t.level != generic {
+				return t
+			}
+			v, ok := fresh[t]
+			if !ok {
+				v = c.newVar()
+				fresh[t] = v
+			}
+			return v
+		case Con:
+			if len(t.Args) == 0 {
+				return t
+			}
+			args := make([]Type, len(t.Args))
+			for i, arg := range t.Args {
+				args[i] = copyType(arg)
+			}
+			return Con{Name: t.Name, Args: args}
+		case *Func:
+			f := &Func{Params: make([]Type, len(t.Params)), Min: t.Min, Ret: copyType(t.Ret)}
+			for i, p := range t.Params {
+				f.Params[i] = copyType(p)
+			}
+			if t.Rest != nil {
+				f.Rest = copyType(t.Rest)
+			}
+			return f
+		}
+		return t
+	}
+	return copyType(t)
+}
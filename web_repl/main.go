@@ -10,6 +10,7 @@ import (
 )
 
 var r repl.REPL
+var runtime *fp.Runtime
 
 func write(format string, a ...interface{}) {
 	output := fmt.Sprintf(format, a...)
@@ -37,14 +38,47 @@ func clearBuffer(this js.Value, p []js.Value) interface{} {
 	return nil
 }
 
+// skipped : bindings already reported as not saved, the workspace is saved after every input
+var skipped = make(map[string]bool)
+
+// saveWorkspace : snapshot of the runtime as a json string, {error: message} if it cannot be taken
+func saveWorkspace(this js.Value, p []js.Value) interface{} {
+	var b strings.Builder
+	errs, err := runtime.Snapshot(&b)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{"error": err.Error()})
+	}
+	for _, err := range errs {
+		if !skipped[err.Error()] {
+			skipped[err.Error()] = true
+			write("workspace: %s, not saved\n", err)
+		}
+	}
+	return js.ValueOf(b.String())
+}
+
+// loadWorkspace : restore a snapshot created by saveWorkspace
+func loadWorkspace(this js.Value, p []js.Value) interface{} {
+	if len(p) == 0 {
+		return js.ValueOf("no workspace")
+	}
+	if err := runtime.LoadSnapshot(strings.NewReader(p[0].String())); err != nil {
+		return js.ValueOf(err.Error())
+	}
+	return js.ValueOf("workspace restored")
+}
+
 func main() {
 	// initialize
 	var welcome string
-	r, welcome = repl.NewFP(fp.NewStdRuntime())
+	runtime = fp.NewStdRuntime()
+	r, welcome = repl.NewFP(runtime)
 	write(welcome)
 
 	js.Global().Set("evaluate", js.FuncOf(evaluate))
 	js.Global().Set("clearBuffer", js.FuncOf(clearBuffer))
+	js.Global().Set("saveWorkspace", js.FuncOf(saveWorkspace))
+	js.Global().Set("loadWorkspace", js.FuncOf(loadWorkspace))
 	// Keep WebAssembly running
 	select {}
 }